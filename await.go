@@ -0,0 +1,55 @@
+package cache2go
+
+import "context"
+
+// waiter is shared by the Await calls blocked on the same key.
+type waiter struct {
+	// done is closed once value is set.
+	done  chan struct{}
+	value interface{}
+	// n counts the blocked Await calls.
+	n int
+}
+
+// Await returns the value stored under key, waiting for it to be
+// set if it is not in the cache. It returns ctx.Err() if ctx is done
+// first.
+func (c *Cache) Await(ctx context.Context, key string) (interface{}, error) {
+	c.lock()
+	if value, ok := c.get(key); ok {
+		c.unlock()
+		return value, nil
+	}
+	w, ok := c.waiters[key]
+	if !ok {
+		if c.waiters == nil {
+			c.waiters = make(map[string]*waiter)
+		}
+		w = &waiter{done: make(chan struct{})}
+		c.waiters[key] = w
+	}
+	w.n++
+	c.unlock()
+
+	select {
+	case <-w.done:
+		return w.value, nil
+	case <-ctx.Done():
+		c.lock()
+		defer c.unlock()
+		w.n--
+		if w.n == 0 && c.waiters[key] == w {
+			delete(c.waiters, key)
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// notifyWaiters wakes up the Await calls blocked on key.
+func (c *Cache) notifyWaiters(key string, value interface{}) {
+	if w, ok := c.waiters[key]; ok {
+		w.value = value
+		close(w.done)
+		delete(c.waiters, key)
+	}
+}
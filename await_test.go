@@ -0,0 +1,41 @@
+package cache2go
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAwait(t *testing.T) {
+	cache := New(0, 0)
+	cache.Set("ready", 1)
+	if v, err := cache.Await(context.Background(), "ready"); err != nil || v != 1 {
+		t.Error("Error awaiting cached key: ", v, err)
+	}
+	result := make(chan interface{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			v, _ := cache.Await(context.Background(), "later")
+			result <- v
+		}()
+	}
+	time.Sleep(5 * time.Millisecond)
+	cache.Set("later", 2)
+	for i := 0; i < 2; i++ {
+		if v := <-result; v != 2 {
+			t.Error("Error awaiting key set later: ", v)
+		}
+	}
+}
+
+func TestAwaitCancel(t *testing.T) {
+	cache := New(0, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if _, err := cache.Await(ctx, "never"); err != context.DeadlineExceeded {
+		t.Error("Error cancelling await: ", err)
+	}
+	if len(cache.waiters) != 0 {
+		t.Error("cancelled await should unregister: ", len(cache.waiters))
+	}
+}
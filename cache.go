@@ -1,177 +1,1590 @@
 package cache2go
 
 import (
-	"container/list"
+	"container/heap"
+	"errors"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// Cache is an LRU cache.
+// Cache is an LRU cache. A Cache must be created with New; the
+// zero value is not usable.
 type Cache struct {
 	sync.RWMutex
 	// MaxEntries is the maximum number of cache entries before
 	// an item is evicted. Zero means no limit.
 	maxEntries int
 
-	lruIndex   *list.List
-	ttlIndex   []*list.Element
-	cache      map[string]*list.Element
+	lruIndex   *lruList
+	ttlIndex   ttlHeap
+	cache      map[string]*lruElement
 	expiration time.Duration
+	// idle is how long entries live after their last access,
+	// zero meaning no idle timeout.
+	idle time.Duration
+	// staleGrace is how long entries are still served after
+	// expiring.
+	staleGrace time.Duration
+	// wheel buckets the expiring entries with SweepWheel.
+	wheel *timingWheel
+	// stop is closed to end the cleanup goroutine. It is nil
+	// while no cleanup goroutine runs.
+	stop chan struct{}
+	// wake tells the cleanup goroutine that the next entry to
+	// expire changed.
+	wake chan struct{}
+
+	minRetention   time.Duration
+	onLockWait     func(time.Duration)
+	logger         func(level, msg string, kv ...interface{})
+	onPanic        func(recovered interface{})
+	onEvicted      func(key string, value interface{})
+	onBeforeEvict  func(key string, value interface{}) bool
+	onSweep        func(removed int)
+	onExpiredBatch func(events []Event)
+	evictionBatch  int
+	validate       func(key string, value interface{}) error
+	overflow       OverflowPolicy
+	sweep          SweepStrategy
+	minSweep       time.Duration
+	maxSweep       time.Duration
+	negative       *Cache
+	waiters        map[string]*waiter
+	loading        map[string]*batchLoad
+	spill          OverflowStore
+	// demote receives the entries evicted for capacity for Tiered.
+	demote         func(key string, value interface{})
+	debug          bool
+	compressor     Compressor
+	compressOver   int
+	adaptiveFactor float64
+	adaptiveMax    time.Duration
+	processStats   bool
+	codec          Codec
+
+	softLimit   int
+	onSoftLimit func(n int)
+	// overSoftLimit is set once onSoftLimit fired until the cache
+	// shrinks back to the soft limit.
+	overSoftLimit bool
+
+	policy evictionPolicy
+	// evictIndex orders the entries for the eviction policies
+	// other than LRU.
+	evictIndex victimHeap
+	// inflation is the score of the last entry evicted by cost
+	// aware eviction.
+	inflation float64
+	lrukK     int
+
+	// sampleEvery and onSample drive the stats sampler.
+	sampleEvery time.Duration
+	onSample    func(Stats)
+	// done is closed by Close to stop the background goroutines.
+	done   chan struct{}
+	closed bool
+
+	// clock replaces time.Now in tests.
+	clock func() time.Time
+	// seq numbers the writes, ordering entries set at the same time.
+	seq uint64
+
+	// maxWeight bounds the total weight of the entries, as
+	// measured by sizer, when positive.
+	maxWeight int64
+	weight    int64
+	sizer     func(key string, value interface{}) int64
+
+	// heapTarget is the heap usage, as given by heapInUse, above
+	// which entries are evicted, when positive.
+	heapTarget uint64
+	heapInUse  func() uint64
+
+	// indexFn and index maintain the secondary index, from the
+	// result of indexFn to the keys of the entries.
+	indexFn func(value interface{}) string
+	index   map[string]map[string]struct{}
+
+	// classLimits bounds the entries of each class; classes holds
+	// the recency list of each class tagged by SetWithClass.
+	classLimits map[string]int
+	classes     map[string]*lruList
+
+	hits, misses, evictions uint64
+	// recent counts the lookups of the last few minutes for HitRate.
+	recent *hitRing
+	// victims holds the evicted entries whose callbacks are
+	// deferred until the lock is released.
+	victims []*entry
+	// expiries holds the expired entries whose expire callbacks
+	// run once the lock is released.
+	expiries []*entry
+	// spilled holds the entries evicted for capacity to hand to
+	// the overflow store and demote once the lock is released.
+	spilled []*entry
+	// sweeping is set while the cleanup goroutine removes expired
+	// entries, which are collected in expiredBatch when
+	// onExpiredBatch is set.
+	sweeping     bool
+	expiredBatch []Event
+	// evictedKeys collects the keys evicted for capacity while
+	// SetResult runs.
+	evictedKeys *[]string
 }
 
 type entry struct {
-	key       string
-	value     interface{}
-	timestamp time.Time
+	// accesses is first to keep it 64 bit aligned for atomic
+	// operations on 32 bit platforms.
+	accesses   uint64
+	key        string
+	value      interface{}
+	timestamp  time.Time
+	lastAccess time.Time
+	// ttl overrides the cache expiration for the entry when it is
+	// not zero.
+	ttl     time.Duration
+	expires time.Time
+	// index is the position of the entry in the ttlIndex heap,
+	// or -1 if the entry does not expire.
+	index int
+
+	// mu serializes WithValue calls on the entry.
+	mu sync.Mutex
+	// refs counts the callers using the value outside the lock.
+	// While it is positive the entry is not evicted for capacity
+	// and its OnEvicted callback is held back.
+	refs    int
+	evicted bool
+	// pinned entries are exempt from eviction for capacity.
+	pinned bool
+	// compressed marks values stored compressed by SetBytes.
+	compressed bool
+	// version counts the writes of the entry.
+	version uint64
+	// seq is the number of the last write of the entry.
+	seq uint64
+	// weight is the size of the entry given by the sizer.
+	weight int64
+	// timer removes the entry at its expiration with SweepTimers.
+	timer *time.Timer
+	// wheelSlot and wheelPos locate the entry in the timing wheel
+	// with SweepWheel.
+	wheelSlot int
+	wheelPos  int
+	// onExpire is called once the entry expires.
+	onExpire func(key string, value interface{})
+	// indexed is the secondary index value of the entry.
+	indexed string
+	// class is the class set by SetWithClass, and classElem the
+	// element of the entry in the recency list of that class.
+	class     string
+	classElem *lruElement
+
+	// The following fields are used by the eviction policies other
+	// than LRU. evictIndex is the position of the entry in the
+	// evictIndex heap.
+	cost       float64
+	score      float64
+	history    []time.Time
+	evictIndex int
+}
+
+// EntryInfo describes a cached entry without exposing its value.
+type EntryInfo struct {
+	Key string
+	// Timestamp is when the value was last set. Expiration is
+	// computed from it.
+	Timestamp time.Time
+	// LastAccess is when the value was last set or retrieved
+	// with Get.
+	LastAccess time.Time
+	// AccessCount is how many times the value was retrieved, as
+	// reported by AccessCount.
+	AccessCount uint64
+	// Expires is when the entry expires. It is the zero time
+	// if the cache has no expiration.
+	Expires time.Time
+}
+
+// Option configures optional Cache behaviour at construction.
+type Option func(*Cache)
+
+// WithMinRetention keeps entries from being evicted to make room
+// for new ones until they have been in the cache for at least d.
+// Eviction skips entries that are too young and picks the next
+// least recently used one instead. If every entry is younger than
+// d the least recently used entry is evicted anyway, so maxEntries
+// stays a hard limit.
+func WithMinRetention(d time.Duration) Option {
+	return func(c *Cache) {
+		c.minRetention = d
+	}
+}
+
+// WithLockWaitHook calls fn with the time each operation spent
+// waiting to acquire the cache lock. It is meant for diagnosing
+// contention; fn runs while the lock is held, may be called
+// concurrently and must not call back into the cache.
+func WithLockWaitHook(fn func(wait time.Duration)) Option {
+	return func(c *Cache) {
+		c.onLockWait = fn
+	}
+}
+
+// WithEvictionBatch collects the entries an operation evicts, as
+// many as it takes to get back within the limits of the cache, and
+// runs their OnEvicted callbacks, and those of any other removal,
+// after the lock has been released instead of while holding it. This
+// keeps large evictions, such as a big insert into a cache created
+// with NewBytesLimited, from stalling the other callers. size is the
+// number of victims expected per operation, used to size the batch,
+// and must be positive; it does not make the cache evict more.
+func WithEvictionBatch(size int) Option {
+	return func(c *Cache) {
+		c.evictionBatch = size
+	}
+}
+
+// WithValidator makes the cache check every value with fn before
+// storing it, including the values restored by Load and Merge.
+// Values for which fn returns an error are not stored: Set drops
+// them silently and SetValidated returns the error.
+func WithValidator(fn func(key string, value interface{}) error) Option {
+	return func(c *Cache) {
+		c.validate = fn
+	}
+}
+
+// WithPanicHandler sets the function receiving the panics recovered
+// from user supplied callbacks such as OnEvicted, the validator,
+// loaders or the lock wait hook. A panicking callback never leaves the cache
+// locked or stops its cleanup; without a handler the panic is
+// silently dropped.
+func WithPanicHandler(fn func(recovered interface{})) Option {
+	return func(c *Cache) {
+		c.onPanic = fn
+	}
+}
+
+// errValidatorPanicked is returned by SetValidated when the
+// validator panics.
+var errValidatorPanicked = errors.New("cache2go: validator panicked")
+
+// OverflowPolicy decides what happens when a new key is added to a
+// cache holding maxEntries entries.
+type OverflowPolicy int
+
+const (
+	// OverflowEvict evicts the least recently used entry to make
+	// room for the new one.
+	OverflowEvict OverflowPolicy = iota
+	// OverflowReject refuses to store the new key. Updates of
+	// keys already in the cache are always stored.
+	OverflowReject
+)
+
+// WithOverflowPolicy sets how a full cache handles new keys. The
+// default is OverflowEvict.
+func WithOverflowPolicy(p OverflowPolicy) Option {
+	return func(c *Cache) {
+		c.overflow = p
+	}
+}
+
+// WithSoftLimit calls warn with the number of entries whenever the
+// cache grows past n entries, once per crossing. It is a guardrail
+// for caches without limit or expiration and never evicts anything.
+// warn runs while the cache is locked and must not call back into it.
+func WithSoftLimit(n int, warn func(n int)) Option {
+	return func(c *Cache) {
+		c.softLimit = n
+		c.onSoftLimit = warn
+	}
+}
+
+// WithAdaptiveTTL lengthens the lifetime of frequently read entries.
+// Each access counted by AccessCount adds factor times the entry's
+// TTL to its lifetime, up to max, so hot entries stay cached while
+// the ones nobody reads expire on schedule. Lifetimes are still
+// measured from the time the value was set.
+func WithAdaptiveTTL(factor float64, max time.Duration) Option {
+	return func(c *Cache) {
+		c.adaptiveFactor = factor
+		c.adaptiveMax = max
+	}
 }
 
 // New creates a new Cache.
 // If maxEntries is zero, the cache has no limit and it's assumed
 // that eviction is done by the caller.
-func New(maxEntries int, expire time.Duration) *Cache {
+func New(maxEntries int, expire time.Duration, opts ...Option) *Cache {
 	c := &Cache{
 		maxEntries: maxEntries,
 		expiration: expire,
-		lruIndex:   list.New(),
-		cache:      make(map[string]*list.Element),
+		lruIndex:   newLRUList(),
+		cache:      make(map[string]*lruElement),
+		wake:       make(chan struct{}, 1),
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.sweep == SweepWheel {
+		c.wheel = new(timingWheel)
 	}
 	if c.expiration > 0 {
-		c.ttlIndex = make([]*list.Element, 0)
-		go c.cleanExpired()
+		c.ttlIndex = make(ttlHeap, 0)
+		c.startCleanup()
+	}
+	if c.sampleEvery > 0 && c.onSample != nil {
+		go c.sample(c.done)
+	}
+	if c.heapTarget > 0 {
+		go c.watchHeap(c.done)
 	}
 	return c
 }
 
-// cleans expired entries performing minimal checks
-func (c *Cache) cleanExpired() {
-	for {
+const errUninitialized = "cache2go: Cache used without being created by New"
+
+// now returns the current time of the cache clock.
+func (c *Cache) now() time.Time {
+	if c.clock != nil {
+		return c.clock()
+	}
+	return time.Now()
+}
+
+func (c *Cache) lock() {
+	if c.onLockWait == nil {
+		c.Lock()
+	} else {
+		start := time.Now()
+		c.Lock()
+		wait := time.Since(start)
+		c.safely(func() { c.onLockWait(wait) })
+	}
+	if c.lruIndex == nil {
+		c.Unlock()
+		panic(errUninitialized)
+	}
+}
+
+func (c *Cache) rlock() {
+	if c.onLockWait == nil {
 		c.RLock()
-		if len(c.ttlIndex) == 0 {
-			c.RUnlock()
-			time.Sleep(c.expiration)
-			continue
+	} else {
+		start := time.Now()
+		c.RLock()
+		wait := time.Since(start)
+		c.safely(func() { c.onLockWait(wait) })
+	}
+	if c.lruIndex == nil {
+		c.RUnlock()
+		panic(errUninitialized)
+	}
+}
+
+// unlock releases the write lock and runs the eviction callbacks
+// deferred while it was held.
+func (c *Cache) unlock() {
+	victims, fn := c.victims, c.onEvicted
+	expiries, spilled := c.expiries, c.spilled
+	c.victims, c.expiries, c.spilled = nil, nil, nil
+	var broken error
+	if c.debug {
+		broken = c.verify()
+	}
+	c.Unlock()
+	if broken != nil {
+		panic(broken)
+	}
+	for _, en := range victims {
+		c.safely(func() { fn(en.key, en.value) })
+	}
+	for _, en := range expiries {
+		c.safely(func() { en.onExpire(en.key, en.value) })
+	}
+	for _, en := range spilled {
+		value := en.value
+		if en.compressed {
+			// the other tier stores the value as set
+			if data, err := c.compressor.Decompress(value.([]byte)); err == nil {
+				value = data
+			}
+		}
+		if c.spill != nil {
+			c.safely(func() { c.spill.Store(en.key, value) })
+		}
+		if c.demote != nil {
+			c.safely(func() { c.demote(en.key, value) })
 		}
-		e := c.ttlIndex[0]
+	}
+}
+
+// safely runs a user supplied callback, recovering from its panics.
+func (c *Cache) safely(fn func()) {
+	defer func() {
+		if r := recover(); r != nil && c.onPanic != nil {
+			c.onPanic(r)
+		}
+	}()
+	fn()
+}
+
+// validateValue runs the validator, if any, on value.
+func (c *Cache) validateValue(key string, value interface{}) (err error) {
+	if c.validate == nil {
+		return nil
+	}
+	err = errValidatorPanicked
+	c.safely(func() { err = c.validate(key, value) })
+	return err
+}
+
+// OnEvicted sets a callback executed when an entry is purged
+// from the cache. Unless the cache uses WithEvictionBatch, fn
+// runs while the cache is locked and must not call back into it.
+func (c *Cache) OnEvicted(fn func(key string, value interface{})) {
+	c.lock()
+	defer c.unlock()
+	c.onEvicted = fn
+}
+
+// OnSweep sets a callback executed after each run of the cleanup
+// goroutine with the number of expired entries it removed. fn runs
+// without the cache locked.
+func (c *Cache) OnSweep(fn func(removed int)) {
+	c.lock()
+	defer c.unlock()
+	c.onSweep = fn
+}
 
+// Event describes an entry removed from the cache.
+type Event struct {
+	Key     string
+	Value   interface{}
+	Expires time.Time
+}
+
+// OnExpiredBatch sets a callback receiving, once per run of the
+// cleanup goroutine, all the expired entries it removed, instead of
+// calling OnEvicted for each of them. Expired entries removed by a
+// lookup or SweepOnce, or held with WithValue or GetWithRelease,
+// still go through OnEvicted. fn runs without the cache locked.
+func (c *Cache) OnExpiredBatch(fn func(events []Event)) {
+	c.lock()
+	defer c.unlock()
+	c.onExpiredBatch = fn
+}
+
+func (c *Cache) startCleanup() {
+	if c.stop == nil && !c.closed && c.sweep != SweepTimers {
+		c.stop = make(chan struct{})
+		go c.cleanExpired(c.stop)
+	}
+}
+
+func (c *Cache) stopCleanup() {
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+// Close stops the background goroutines of the cache: the cleanup
+// of expired entries, the stats sampler and the heap watcher of
+// NewMemoryAware. The cache remains usable, but expired entries are
+// only removed when looked up or swept with SweepOnce. Close is
+// idempotent.
+func (c *Cache) Close() {
+	c.lock()
+	if !c.closed {
+		c.closed = true
+		c.stopCleanup()
+		for _, en := range c.ttlIndex {
+			c.disarm(en)
+		}
+		close(c.done)
+	}
+	c.unlock()
+	if c.negative != nil {
+		c.negative.Close()
+	}
+}
+
+// idleCleanup is how long the cleanup goroutine sleeps when nothing
+// is scheduled to expire and the cache has no expiration.
+const idleCleanup = time.Hour
+
+// cleans expired entries, sleeping until the next one is due
+func (c *Cache) cleanExpired(stop chan struct{}) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var backoff time.Duration
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		case <-c.wake:
+		}
+		c.lock()
+		now := c.now()
+		var removed int
+		c.sweeping = true
+		wait := c.expiration
+		switch c.sweep {
+		case SweepSampled:
+			removed = c.removeSampled(now)
+			if len(c.ttlIndex) > 0 {
+				wait = sampledSweepInterval
+			}
+		case SweepWheel:
+			removed = c.turnWheel(now)
+			if len(c.ttlIndex) > 0 {
+				wait = wheelTick
+			}
+		default:
+			removed = c.removeExpired(now)
+			if len(c.ttlIndex) > 0 {
+				wait = c.ttlIndex[0].expires.Add(c.staleGrace).Sub(now)
+			}
+		}
+		if wait <= 0 {
+			wait = idleCleanup
+		}
+		wait, backoff = c.boundSweep(wait, len(c.ttlIndex) == 0, backoff)
+		c.sweeping = false
+		onSweep, onBatch, batch := c.onSweep, c.onExpiredBatch, c.expiredBatch
+		c.expiredBatch = nil
+		c.unlock()
+		if len(batch) > 0 {
+			c.safely(func() { onBatch(batch) })
+		}
+		if onSweep != nil {
+			c.safely(func() { onSweep(removed) })
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+	}
+}
+
+// schedule places en in the TTL index according to its timestamp,
+// ttl and last access, starting the cleanup if it is not running.
+func (c *Cache) schedule(en *entry) {
+	expires, ok := c.deadline(en)
+	switch {
+	case !ok:
+		if en.index >= 0 {
+			c.ttlRemove(en)
+			c.disarm(en)
+		}
+		return
+	case en.index >= 0:
+		en.expires = expires
+		c.ttlFix(en)
+	default:
+		en.expires = expires
+		c.ttlPush(en)
+		c.startCleanup()
+	}
+	if c.sweep == SweepTimers {
+		c.arm(en)
+		return
+	}
+	// the sampled and wheel sweeps only need waking when they went
+	// idle
+	if en.index == 0 && (c.sweep == SweepFull || len(c.ttlIndex) == 1) {
+		select {
+		case c.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// deadline returns when en expires, which is the earliest of its
+// lifetime after its timestamp and the idle timeout after its last
+// access, or false if it never expires.
+func (c *Cache) deadline(en *entry) (time.Time, bool) {
+	ttl := c.lifetime(en)
+	var expires time.Time
+	if ttl > 0 {
+		expires = en.timestamp.Add(ttl)
+	}
+	if c.idle > 0 {
+		if idle := en.lastAccess.Add(c.idle); ttl <= 0 || idle.Before(expires) {
+			expires = idle
+		}
+	}
+	return expires, ttl > 0 || c.idle > 0
+}
+
+// lifetime returns how long en lives after its timestamp, or zero
+// if it never expires.
+func (c *Cache) lifetime(en *entry) time.Duration {
+	ttl := en.ttl
+	if ttl == 0 {
+		ttl = c.expiration
+	}
+	if c.adaptiveFactor > 0 && ttl > 0 {
+		extended := time.Duration(float64(ttl) * (1 + c.adaptiveFactor*float64(atomic.LoadUint64(&en.accesses))))
+		if extended > c.adaptiveMax || extended < ttl {
+			extended = c.adaptiveMax
+		}
+		if extended > ttl {
+			ttl = extended
+		}
+	}
+	return ttl
+}
+
+// SetExpiration changes the expiration of the cache. Existing
+// entries expire d after their timestamp; a zero d disables
+// expiration and, unless some entries have their own TTL, stops
+// the cleanup goroutine.
+func (c *Cache) SetExpiration(d time.Duration) {
+	c.lock()
+	defer c.unlock()
+	c.expiration = d
+	// restart the cleanup so it picks up the new schedule
+	c.stopCleanup()
+	c.rebuildTTLIndex()
+	if d > 0 {
+		c.startCleanup()
+	}
+}
+
+// rebuildTTLIndex recomputes the expiration of every entry and
+// rebuilds the ttlIndex in one pass, which is cheaper than fixing the
+// heap one entry at a time after changing many of them, and wakes up
+// the cleanup so it sees the new next entry to expire.
+func (c *Cache) rebuildTTLIndex() {
+	c.ttlIndex = c.ttlIndex[:0]
+	if c.sweep == SweepWheel {
+		c.wheel = &timingWheel{next: c.wheel.next}
+	}
+	for e := c.lruIndex.Front(); e != nil; e = e.Next() {
 		en := e.Value.(*entry)
-		exp := en.timestamp.Add(c.expiration)
-		c.RUnlock()
-		if time.Now().After(exp) {
-			c.Lock()
-			c.removeElement(e)
-			c.Unlock()
+		en.index = -1
+		if expires, ok := c.deadline(en); ok {
+			en.expires = expires
+			en.index = len(c.ttlIndex)
+			c.ttlIndex = append(c.ttlIndex, en)
+			switch c.sweep {
+			case SweepTimers:
+				c.arm(en)
+			case SweepWheel:
+				c.wheelAdd(en)
+			}
 		} else {
-			time.Sleep(time.Now().Sub(exp))
+			c.disarm(en)
+		}
+	}
+	if c.sweep != SweepWheel {
+		heap.Init(&c.ttlIndex)
+	}
+	if len(c.ttlIndex) > 0 {
+		c.startCleanup()
+		select {
+		case c.wake <- struct{}{}:
+		default:
 		}
 	}
 }
 
+// SweepOnce synchronously removes all the entries that have
+// expired and returns how many there were, independently of the
+// background cleanup.
+func (c *Cache) SweepOnce() int {
+	c.lock()
+	defer c.unlock()
+	return c.removeExpired(c.now())
+}
+
+// Compact removes all the expired entries and releases the memory
+// the cache indexes kept from earlier churn. It returns how many
+// entries remain and how many were removed. It takes time
+// proportional to the size of the cache and is meant to be called
+// occasionally by long running processes.
+func (c *Cache) Compact() (remaining, removed int) {
+	c.lock()
+	defer c.unlock()
+	removed = c.removeExpired(c.now())
+	if cap(c.ttlIndex) > 2*len(c.ttlIndex) {
+		c.ttlIndex = append(make(ttlHeap, 0, len(c.ttlIndex)), c.ttlIndex...)
+	}
+	if h := c.evictIndex.entries; cap(h) > 2*len(h) {
+		c.evictIndex.entries = append(make([]*entry, 0, len(h)), h...)
+	}
+	// maps never shrink, so copy the live entries to a new one
+	m := make(map[string]*lruElement, len(c.cache))
+	for k, e := range c.cache {
+		m[k] = e
+	}
+	c.cache = m
+	return c.lruIndex.Len(), removed
+}
+
+// removeExpired removes the entries that expired at or before now
+// and returns how many there were.
+func (c *Cache) removeExpired(now time.Time) int {
+	if c.sweep == SweepWheel {
+		return c.turnWheel(now)
+	}
+	n := 0
+	for len(c.ttlIndex) > 0 && c.expired(c.ttlIndex[0], now) {
+		c.expire(c.cache[c.ttlIndex[0].key])
+		n++
+	}
+	return n
+}
+
 // Add adds a value to the cache
 func (c *Cache) Set(key string, value interface{}) {
-	c.Lock()
-	if c.cache == nil {
-		c.cache = make(map[string]*list.Element)
-		c.lruIndex = list.New()
-		if c.expiration > 0 {
-			c.ttlIndex = make([]*list.Element, 0)
-		}
+	c.SetValidated(key, value)
+}
+
+// SetWithResult adds a value to the cache and reports whether it
+// was stored. It is false if the value failed validation or if the
+// cache uses OverflowReject and is full.
+func (c *Cache) SetWithResult(key string, value interface{}) bool {
+	if c.validateValue(key, value) != nil {
+		return false
+	}
+	c.lock()
+	defer c.unlock()
+	_, _, stored := c.set(key, value, 1, 0)
+	return stored
+}
+
+// SetValidated adds a value to the cache if it passes the
+// validator configured with WithValidator, and returns the
+// validation error otherwise.
+func (c *Cache) SetValidated(key string, value interface{}) error {
+	if err := c.validateValue(key, value); err != nil {
+		return err
 	}
+	c.lock()
+	c.set(key, value, 1, 0)
+	c.unlock()
+	return nil
+}
 
-	if e, ok := c.cache[key]; ok {
-		c.lruIndex.MoveToFront(e)
+// GetSet sets the value for key and returns the value it replaced,
+// if any, in a single atomic step. A value rejected by the validator
+// is not stored and GetSet returns nil and false for it.
+func (c *Cache) GetSet(key string, value interface{}) (old interface{}, existed bool) {
+	if c.validateValue(key, value) != nil {
+		return nil, false
+	}
+	c.lock()
+	defer c.unlock()
+	old, existed, _ = c.set(key, value, 1, 0)
+	return old, existed
+}
+
+// LoadOrStore returns the existing value for key if present and
+// marks it as recently used. Otherwise it stores value and returns
+// it. The loaded result is true if the value was loaded, false if
+// stored. A value rejected by the validator or by a full
+// OverflowReject cache is not stored and LoadOrStore returns nil
+// for it.
+func (c *Cache) LoadOrStore(key string, value interface{}) (actual interface{}, loaded bool) {
+	invalid := c.validateValue(key, value) != nil
+	c.lock()
+	defer c.unlock()
+	if e := c.live(key, c.now()); e != nil {
+		c.access(e)
+		return e.Value.(*entry).value, true
+	}
+	if invalid {
+		return nil, false
+	}
+	if _, _, stored := c.set(key, value, 1, 0); !stored {
+		return nil, false
+	}
+	return value, false
+}
+
+// set stores value under key. A non zero ttl overrides the cache
+// expiration for the entry. It returns the replaced value, if any,
+// and whether value was stored, which it is not if the entry had to
+// be evicted right away because nothing else could make room.
+// Callers looking the entry up after a set must check stored.
+func (c *Cache) set(key string, value interface{}, cost float64, ttl time.Duration) (old interface{}, existed, stored bool) {
+	e, ok := c.cache[key]
+	if !ok && c.overflow == OverflowReject && c.full() {
+		return nil, false, false
+	}
+	if c.negative != nil {
+		c.negative.Delete(key)
+	}
+	if ok {
+		c.promote(e)
 
 		en := e.Value.(*entry)
+		old = en.value
 		en.value = value
-		en.timestamp = time.Now()
-
-		c.Unlock()
-		return
+		en.timestamp = c.now()
+		en.lastAccess = en.timestamp
+		en.ttl = ttl
+		en.compressed = false
+		en.version++
+		c.seq++
+		en.seq = c.seq
+		en.onExpire = nil
+		if c.indexFn != nil {
+			c.reindex(en)
+		}
+		c.schedule(en)
+		if c.policy != policyLRU {
+			en.cost = cost
+			c.prioritize(en)
+		}
+		if c.maxWeight > 0 {
+			c.reweigh(en)
+		}
+		c.notifyWaiters(key, value)
+		return old, true, c.holds(key, en)
 	}
-	e := c.lruIndex.PushFront(&entry{key: key, value: value, timestamp: time.Now()})
-	if c.expiration > 0 {
-		c.ttlIndex = append(c.ttlIndex, e)
+	now := c.now()
+	c.seq++
+	en := &entry{key: key, value: value, timestamp: now, lastAccess: now, ttl: ttl, index: -1, evictIndex: -1, version: 1, seq: c.seq}
+	e = c.lruIndex.PushFront(en)
+	if c.indexFn != nil {
+		c.reindex(en)
+	}
+	c.schedule(en)
+	if c.policy != policyLRU {
+		en.cost = cost
+		c.prioritize(en)
 	}
 	c.cache[key] = e
+	c.notifyWaiters(key, value)
+	if c.softLimit > 0 {
+		c.checkSoftLimit()
+	}
 
 	if c.maxEntries != 0 && c.lruIndex.Len() > c.maxEntries {
-		c.removeOldest()
+		c.removeOldest(en)
 	}
-	c.Unlock()
+	if c.maxWeight > 0 {
+		c.reweigh(en)
+	}
+	return nil, false, c.holds(key, en)
 }
 
-// Get looks up a key's value from the cache.
-func (c *Cache) Get(key string) (value interface{}, ok bool) {
-	c.Lock()
-	defer c.Unlock()
-	if c.cache == nil {
+// holds reports whether en is the entry stored under key, which it
+// no longer is once removed, even if the key was set again since.
+func (c *Cache) holds(key string, en *entry) bool {
+	e, ok := c.cache[key]
+	return ok && e.Value.(*entry) == en
+}
+
+func (c *Cache) checkSoftLimit() {
+	n := c.lruIndex.Len()
+	if n <= c.softLimit {
+		c.overSoftLimit = false
 		return
 	}
-	if e, hit := c.cache[key]; hit {
-		c.lruIndex.MoveToFront(e)
+	if !c.overSoftLimit {
+		c.overSoftLimit = true
+		c.safely(func() { c.onSoftLimit(n) })
+	}
+}
+
+// full reports whether the cache holds maxEntries live entries,
+// removing the expired ones to make room first.
+func (c *Cache) full() bool {
+	if c.maxEntries == 0 || c.lruIndex.Len() < c.maxEntries {
+		return false
+	}
+	c.removeExpired(c.now())
+	return c.lruIndex.Len() >= c.maxEntries
+}
+
+// Get looks up a key's value from the cache. An entry past its
+// expiration is removed and reported as missing even if the cleanup
+// did not get to it yet.
+func (c *Cache) Get(key string) (value interface{}, ok bool) {
+	c.lock()
+	value, ok = c.get(key)
+	c.unlock()
+	if !ok && c.spill != nil {
+		return c.unspill(key)
+	}
+	return value, ok
+}
+
+func (c *Cache) get(key string) (value interface{}, ok bool) {
+	now := c.now()
+	if e := c.live(key, now); e != nil {
+		c.access(e)
+		c.lookup(true, now)
 		return e.Value.(*entry).value, true
 	}
+	c.lookup(false, now)
 	return
 }
 
-// Remove removes the provided key from the cache.
-func (c *Cache) Delete(key string) {
-	c.Lock()
-	defer c.Unlock()
-	if c.cache == nil {
-		return
+// GetWithRank is like Get but also returns the position the entry
+// had in the LRU order before being read, 0 being the most recently
+// used and Len()-1 the next one to be evicted. Finding the rank
+// takes time proportional to it.
+func (c *Cache) GetWithRank(key string) (value interface{}, rank int, ok bool) {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	e := c.live(key, now)
+	if e == nil {
+		c.lookup(false, now)
+		return nil, 0, false
+	}
+	for p := e.Prev(); p != nil; p = p.Prev() {
+		rank++
+	}
+	c.access(e)
+	c.lookup(true, now)
+	return e.Value.(*entry).value, rank, true
+}
+
+// GetManyStale looks up several keys at once. Live values are
+// returned in fresh and marked as recently used; values past their
+// expiration that the cleanup did not remove yet are returned in
+// stale and left in place, so they can be served while refreshing.
+func (c *Cache) GetManyStale(keys []string) (fresh map[string]interface{}, stale map[string]interface{}) {
+	fresh = make(map[string]interface{})
+	stale = make(map[string]interface{})
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	for _, key := range keys {
+		e, hit := c.cache[key]
+		if !hit {
+			c.lookup(false, now)
+			continue
+		}
+		en := e.Value.(*entry)
+		if c.stale(en, now) {
+			stale[key] = en.value
+			c.lookup(false, now)
+			continue
+		}
+		c.access(e)
+		c.lookup(true, now)
+		fresh[key] = en.value
+	}
+	return fresh, stale
+}
+
+// expired reports whether en is past its expiration and stale
+// grace at now, and so is gone.
+func (c *Cache) expired(en *entry, now time.Time) bool {
+	return en.index >= 0 && !now.Before(en.expires.Add(c.staleGrace))
+}
+
+// stale reports whether en is past its expiration at now, including
+// during its stale grace.
+func (c *Cache) stale(en *entry, now time.Time) bool {
+	return en.index >= 0 && !now.Before(en.expires)
+}
+
+// live returns the element for key unless it is missing or expired,
+// removing it in the latter case.
+func (c *Cache) live(key string, now time.Time) *lruElement {
+	e, hit := c.cache[key]
+	if !hit {
+		return nil
+	}
+	if c.expired(e.Value.(*entry), now) {
+		c.expire(e)
+		return nil
+	}
+	return e
+}
+
+// access records a read of the entry.
+func (c *Cache) access(e *lruElement) {
+	c.promote(e)
+	en := e.Value.(*entry)
+	en.lastAccess = c.now()
+	atomic.AddUint64(&en.accesses, 1)
+	if (c.adaptiveFactor > 0 || c.idle > 0) && en.index >= 0 {
+		c.schedule(en)
+	}
+	if c.policy != policyLRU {
+		c.prioritize(en)
+	}
+}
+
+// promote makes e the most recently used entry, in its class too.
+func (c *Cache) promote(e *lruElement) {
+	c.lruIndex.MoveToFront(e)
+	if en := e.Value.(*entry); en.classElem != nil {
+		c.classes[en.class].MoveToFront(en.classElem)
+	}
+}
+
+// WithValue calls fn with the value stored under key and, if fn
+// returns a non-nil value, stores it in place of the old one
+// without refreshing the entry's timestamp. While fn runs the entry
+// is not evicted for capacity, and if it is removed in the meantime
+// its OnEvicted callback is held back until fn returns. Concurrent
+// WithValue calls on the same key are serialized; fn must not call
+// WithValue for the same key. WithValue reports whether key was
+// found. If fn panics the entry is released and the panic is passed
+// on to the caller. The value fn returns is stored uncompressed,
+// even if it received bytes compressed by SetBytes.
+func (c *Cache) WithValue(key string, fn func(value interface{}) interface{}) bool {
+	c.lock()
+	e, hit := c.cache[key]
+	if !hit {
+		c.unlock()
+		return false
+	}
+	en := e.Value.(*entry)
+	en.refs++
+	c.unlock()
+
+	en.mu.Lock()
+	defer en.mu.Unlock()
+	// store the update before the next caller can take mu
+	var updated interface{}
+	defer func() {
+		c.lock()
+		// an entry removed or replaced meanwhile only gets its
+		// value updated, for its OnEvicted callback
+		if updated != nil {
+			en.value = updated
+			en.compressed = false
+			if c.holds(en.key, en) {
+				if c.indexFn != nil {
+					c.reindex(en)
+				}
+				if c.maxWeight > 0 {
+					c.reweigh(en)
+				}
+			}
+		}
+		c.release(en)
+		c.unlock()
+	}()
+	c.rlock()
+	value := en.value
+	c.RUnlock()
+	updated = fn(value)
+	return true
+}
+
+// GetWithRelease is like Get but holds the entry until release is
+// called, for values that are recycled once evicted, e.g. returned to
+// a pool from OnEvicted. The entry is not evicted for capacity while
+// held, and if it is deleted, expires or is flushed meanwhile its
+// OnEvicted callback runs only after the last release. Calling
+// release more than once has no effect.
+func (c *Cache) GetWithRelease(key string) (value interface{}, release func(), ok bool) {
+	c.lock()
+	defer c.unlock()
+	value, ok = c.get(key)
+	if !ok {
+		return nil, func() {}, false
+	}
+	en := c.cache[key].Value.(*entry)
+	en.refs++
+	var once sync.Once
+	return value, func() {
+		once.Do(func() {
+			c.lock()
+			c.release(en)
+			c.unlock()
+		})
+	}, true
+}
+
+// release drops a reference taken on en, delivering its eviction
+// callback if the entry was removed while in use.
+func (c *Cache) release(en *entry) {
+	en.refs--
+	if en.refs == 0 && en.evicted {
+		en.evicted = false
+		c.evicted(en)
 	}
+}
+
+// Peek looks up a key's value from the cache without
+// updating its recency. Expired entries are reported as missing.
+func (c *Cache) Peek(key string) (value interface{}, ok bool) {
+	c.rlock()
+	defer c.RUnlock()
+	if e, hit := c.cache[key]; hit && !c.expired(e.Value.(*entry), c.now()) {
+		return e.Value.(*entry).value, true
+	}
+	return
+}
+
+// Contains reports whether key is in the cache without
+// updating its recency. Expired entries are reported as missing.
+func (c *Cache) Contains(key string) bool {
+	c.rlock()
+	defer c.RUnlock()
+	e, ok := c.cache[key]
+	return ok && !c.expired(e.Value.(*entry), c.now())
+}
+
+// peekStale returns the value stored under key even if it expired,
+// without updating its recency.
+func (c *Cache) peekStale(key string) (value interface{}, ok bool) {
+	c.rlock()
+	defer c.RUnlock()
 	if e, hit := c.cache[key]; hit {
-		c.removeElement(e)
+		return e.Value.(*entry).value, true
+	}
+	return nil, false
+}
+
+// NoExpiration is the remaining lifetime GetTTL reports for entries
+// that never expire.
+const NoExpiration = time.Duration(1<<63 - 1)
+
+// GetTTL returns how long until the entry under key expires, without
+// updating its recency. It is zero or negative if the entry expired
+// but was not removed yet, and NoExpiration if it never expires.
+func (c *Cache) GetTTL(key string) (remaining time.Duration, ok bool) {
+	c.rlock()
+	defer c.RUnlock()
+	e, ok := c.cache[key]
+	if !ok {
+		return 0, false
+	}
+	en := e.Value.(*entry)
+	if en.index < 0 {
+		return NoExpiration, true
+	}
+	return en.expires.Sub(c.now()), true
+}
+
+// Keys returns the live keys ordered from the most to the least
+// recently used.
+func (c *Cache) Keys() []string {
+	c.rlock()
+	defer c.RUnlock()
+	now := c.now()
+	keys := make([]string, 0, c.lruIndex.Len())
+	for e := c.lruIndex.Front(); e != nil; e = e.Next() {
+		if en := e.Value.(*entry); !c.expired(en, now) {
+			keys = append(keys, en.key)
+		}
 	}
+	return keys
 }
 
-// RemoveOldest removes the oldest item from the cache.
-func (c *Cache) removeOldest() {
-	if c.cache == nil {
+// MostRecent returns up to n live values from the most to the least
+// recently used, without updating their recency.
+func (c *Cache) MostRecent(n int) []interface{} {
+	c.rlock()
+	defer c.RUnlock()
+	now := c.now()
+	var values []interface{}
+	for e := c.lruIndex.Front(); e != nil && len(values) < n; e = e.Next() {
+		if en := e.Value.(*entry); !c.expired(en, now) {
+			values = append(values, en.value)
+		}
+	}
+	return values
+}
+
+// LeastRecent returns up to n live values from the least to the most
+// recently used, without updating their recency.
+func (c *Cache) LeastRecent(n int) []interface{} {
+	c.rlock()
+	defer c.RUnlock()
+	now := c.now()
+	var values []interface{}
+	for e := c.lruIndex.Back(); e != nil && len(values) < n; e = e.Prev() {
+		if en := e.Value.(*entry); !c.expired(en, now) {
+			values = append(values, en.value)
+		}
+	}
+	return values
+}
+
+// AccessCount returns how many times the entry stored under key
+// was retrieved with Get or LoadOrStore. The count starts at zero
+// when the key is added and is kept when its value is updated, so
+// it measures how popular the key is rather than the value.
+func (c *Cache) AccessCount(key string) (uint64, bool) {
+	c.rlock()
+	defer c.RUnlock()
+	if e, hit := c.cache[key]; hit {
+		return atomic.LoadUint64(&e.Value.(*entry).accesses), true
+	}
+	return 0, false
+}
+
+// EntryInfo returns metadata about the entry stored under key
+// without updating its recency.
+func (c *Cache) EntryInfo(key string) (info EntryInfo, ok bool) {
+	c.rlock()
+	defer c.RUnlock()
+	e, hit := c.cache[key]
+	if !hit {
 		return
 	}
-	e := c.lruIndex.Back()
-	if e != nil {
-		c.removeElement(e)
+	en := e.Value.(*entry)
+	info = EntryInfo{
+		Key:         en.key,
+		Timestamp:   en.timestamp,
+		LastAccess:  en.lastAccess,
+		AccessCount: atomic.LoadUint64(&en.accesses),
+	}
+	if en.index >= 0 {
+		info.Expires = en.expires
 	}
+	return info, true
 }
 
-func (c *Cache) removeElement(e *list.Element) {
-	c.lruIndex.Remove(e)
-	if c.expiration > 0 {
-		for i, se := range c.ttlIndex {
-			if se == e {
-				//delete
-				copy(c.ttlIndex[i:], c.ttlIndex[i+1:])
-				c.ttlIndex[len(c.ttlIndex)-1] = nil
-				c.ttlIndex = c.ttlIndex[:len(c.ttlIndex)-1]
-				break
+// ExpiringSoon returns up to n keys ordered by how soon they
+// expire, soonest first. It returns nil if the cache has no
+// expiration.
+func (c *Cache) ExpiringSoon(n int) []string {
+	c.rlock()
+	defer c.RUnlock()
+	if n > len(c.ttlIndex) {
+		n = len(c.ttlIndex)
+	}
+	if n <= 0 {
+		return nil
+	}
+	if c.sweep == SweepWheel {
+		return c.wheelExpiringSoon(n)
+	}
+	keys := make([]string, 0, n)
+	// walk the heap in order by expanding the frontier of
+	// positions to the children of each one taken
+	frontier := &positionHeap{h: c.ttlIndex, pos: []int{0}}
+	for len(keys) < n {
+		i := heap.Pop(frontier).(int)
+		keys = append(keys, c.ttlIndex[i].key)
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(c.ttlIndex) {
+				heap.Push(frontier, child)
 			}
 		}
 	}
-	if e.Value != nil {
-		kv := e.Value.(*entry)
+	return keys
+}
+
+// Rename moves the entry stored under oldKey to newKey, keeping
+// its value, timestamp and recency. An entry already stored under
+// newKey is overwritten and purged as if deleted. Rename returns
+// false if oldKey is not in the cache.
+func (c *Cache) Rename(oldKey, newKey string) bool {
+	c.lock()
+	defer c.unlock()
+	e, hit := c.cache[oldKey]
+	if !hit {
+		return false
+	}
+	if oldKey == newKey {
+		return true
+	}
+	if other, ok := c.cache[newKey]; ok {
+		c.removeElement(other)
+	}
+	delete(c.cache, oldKey)
+	en := e.Value.(*entry)
+	if c.indexFn != nil {
+		c.unindex(en)
+	}
+	en.key = newKey
+	if c.indexFn != nil {
+		c.reindex(en)
+	}
+	c.cache[newKey] = e
+	if c.negative != nil {
+		c.negative.Delete(newKey)
+	}
+	return true
+}
+
+// ExpiryProfile counts the entries by remaining lifetime. buckets
+// holds ascending upper bounds: the result has len(buckets)+2
+// counts, where count i holds the live entries expiring within
+// buckets[i] but not within buckets[i-1], the next one the live
+// entries expiring later or never, and the last one the entries
+// already expired but not yet removed.
+func (c *Cache) ExpiryProfile(buckets []time.Duration) []int {
+	c.rlock()
+	defer c.RUnlock()
+	counts := make([]int, len(buckets)+2)
+	now := c.now()
+	for _, en := range c.ttlIndex {
+		remaining := en.expires.Sub(now)
+		if remaining <= 0 {
+			counts[len(buckets)+1]++
+			continue
+		}
+		counts[sort.Search(len(buckets), func(i int) bool {
+			return remaining <= buckets[i]
+		})]++
+	}
+	counts[len(buckets)] += c.lruIndex.Len() - len(c.ttlIndex)
+	return counts
+}
+
+// Remove removes the provided key from the cache.
+func (c *Cache) Delete(key string) {
+	c.lock()
+	defer c.unlock()
+	if e, hit := c.cache[key]; hit {
+		c.removeElement(e)
+	}
+}
+
+// RemoveOldest removes the oldest item from the cache to make room
+// for keep, the entry being added, if any. Entries in use are never
+// removed; if no other entry can go, keep itself is removed.
+func (c *Cache) removeOldest(keep *entry) {
+	if c.policy != policyLRU {
+		c.evict(c.nextByPriority(keep), keep)
+	} else {
+		c.evict(c.leastRecent(c.lruIndex, keep), keep)
+	}
+}
+
+// leastRecent returns the least recently used entry of l that may be
+// evicted for keep, preferring those evictable, or nil if there is
+// none besides keep that is not spared.
+func (c *Cache) leastRecent(l *lruList, keep *entry) *entry {
+	var victim *entry
+	now := c.now()
+	for e := l.Back(); e != nil; e = e.Prev() {
+		en := e.Value.(*entry)
+		if c.spared(en, keep) {
+			continue
+		}
+		if c.evictable(en, now) {
+			return en
+		}
+		if victim == nil {
+			victim = en
+		}
+	}
+	return victim
+}
+
+// evict removes en for capacity, or keep if en is nil.
+func (c *Cache) evict(en *entry, keep *entry) {
+	var victim *lruElement
+	if en != nil {
+		victim = c.cache[en.key]
+	}
+	if victim == nil {
+		if keep == nil {
+			return
+		}
+		if victim = c.cache[keep.key]; victim == nil || victim.Value.(*entry) != keep {
+			return
+		}
+	}
+	if c.policy == policyCost {
+		c.inflation = victim.Value.(*entry).score
+	}
+	if c.logger != nil {
+		c.log("debug", "evicted", "key", victim.Value.(*entry).key, "len", c.lruIndex.Len())
+	}
+	if c.spill != nil || c.demote != nil {
+		c.spilled = append(c.spilled, victim.Value.(*entry))
+	}
+	if c.evictedKeys != nil {
+		*c.evictedKeys = append(*c.evictedKeys, victim.Value.(*entry).key)
+	}
+	c.removeElement(victim)
+	c.evictions++
+}
+
+func (c *Cache) removeElement(e *lruElement) {
+	if v := c.lruIndex.Remove(e); v != nil {
+		kv := v.(*entry)
+		if kv.index >= 0 {
+			c.ttlRemove(kv)
+			c.disarm(kv)
+		}
+		if kv.evictIndex >= 0 {
+			heap.Remove(&c.evictIndex, kv.evictIndex)
+		}
 		delete(c.cache, kv.key)
+		c.weight -= kv.weight
+		if c.indexFn != nil {
+			c.unindex(kv)
+		}
+		c.unclass(kv)
+		c.evicted(kv)
 	}
 }
 
+// expire removes the expired entry e.
+func (c *Cache) expire(e *lruElement) {
+	en := e.Value.(*entry)
+	if c.logger != nil {
+		c.log("debug", "expired", "key", en.key, "expires", en.expires)
+	}
+	c.removeElement(e)
+	c.evictions++
+	if en.onExpire != nil {
+		c.expiries = append(c.expiries, en)
+	}
+}
+
+// evictable reports whether en may be evicted to make room for
+// other entries.
+func (c *Cache) evictable(en *entry, now time.Time) bool {
+	if en.refs > 0 || en.pinned {
+		return false
+	}
+	return c.minRetention == 0 || now.Sub(en.timestamp) >= c.minRetention
+}
+
+func (c *Cache) evicted(en *entry) {
+	if c.sweeping && c.onExpiredBatch != nil && en.refs == 0 {
+		c.expiredBatch = append(c.expiredBatch, Event{Key: en.key, Value: en.value, Expires: en.expires})
+		return
+	}
+	if c.onEvicted == nil {
+		return
+	}
+	if en.refs > 0 {
+		// delivered once the value is released
+		en.evicted = true
+		return
+	}
+	if c.evictionBatch > 0 {
+		if c.victims == nil {
+			c.victims = make([]*entry, 0, c.evictionBatch)
+		}
+		c.victims = append(c.victims, en)
+		return
+	}
+	c.safely(func() { c.onEvicted(en.key, en.value) })
+}
+
 // Len returns the number of items in the cache.
 func (c *Cache) Len() int {
-	c.RLock()
+	c.rlock()
 	defer c.RUnlock()
-	if c.cache == nil {
-		return 0
-	}
 	return c.lruIndex.Len()
 }
 
+// LenByState splits Len into the live entries and those that expired
+// but were not removed yet, which measures how far behind the cleanup
+// is.
+func (c *Cache) LenByState() (live, expired int) {
+	c.rlock()
+	defer c.RUnlock()
+	now := c.now()
+	if c.sweep == SweepWheel {
+		for _, en := range c.ttlIndex {
+			if c.expired(en, now) {
+				expired++
+			}
+		}
+		return c.lruIndex.Len() - expired, expired
+	}
+	// the expired entries form a subtree at the root of the heap
+	stack := []int{0}
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if i < len(c.ttlIndex) && c.expired(c.ttlIndex[i], now) {
+			expired++
+			stack = append(stack, 2*i+1, 2*i+2)
+		}
+	}
+	return c.lruIndex.Len() - expired, expired
+}
+
 // empties the whole cache
 func (c *Cache) Flush() {
-	c.Lock()
-	defer c.Unlock()
-	c.lruIndex = list.New()
-	if c.expiration > 0 {
-		c.ttlIndex = make([]*list.Element, 0)
+	c.lock()
+	defer c.unlock()
+	c.flush()
+}
+
+// Drain empties the cache in a single step and returns the values
+// that were live by key, for instance to persist them on shutdown.
+// Unlike Flush, it calls no eviction callbacks.
+func (c *Cache) Drain() map[string]interface{} {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	items := make(map[string]interface{}, len(c.cache))
+	for k, e := range c.cache {
+		if en := e.Value.(*entry); !c.expired(en, now) {
+			items[k] = en.value
+		}
+	}
+	if c.logger != nil {
+		c.log("info", "drained", "len", c.lruIndex.Len())
+	}
+	c.reset()
+	return items
+}
+
+// ReplaceAll replaces the whole content of the cache with items in
+// a single step, so readers see either the old or the new entries.
+// The old entries are purged as by Flush. Items failing validation
+// are skipped, and if there are more than maxEntries items some of
+// them are evicted.
+func (c *Cache) ReplaceAll(items map[string]interface{}) {
+	valid := make(map[string]interface{}, len(items))
+	for k, v := range items {
+		if c.validateValue(k, v) == nil {
+			valid[k] = v
+		}
+	}
+	c.lock()
+	defer c.unlock()
+	c.flush()
+	for k, v := range valid {
+		c.set(k, v, 1, 0)
+	}
+}
+
+func (c *Cache) flush() {
+	if c.logger != nil {
+		c.log("info", "flushed", "len", c.lruIndex.Len())
+	}
+	if c.onEvicted != nil {
+		for e := c.lruIndex.Back(); e != nil; e = e.Prev() {
+			c.evicted(e.Value.(*entry))
+		}
+	}
+	c.reset()
+}
+
+// reset empties the cache without calling any callback.
+func (c *Cache) reset() {
+	for _, en := range c.ttlIndex {
+		c.disarm(en)
+	}
+	c.lruIndex = newLRUList()
+	c.weight = 0
+	c.ttlIndex = nil
+	if c.wheel != nil {
+		c.wheel = &timingWheel{next: c.wheel.next}
+	}
+	c.evictIndex.entries = nil
+	c.cache = make(map[string]*lruElement)
+	c.classes = nil
+	if c.indexFn != nil {
+		c.index = make(map[string]map[string]struct{})
 	}
-	c.cache = make(map[string]*list.Element)
 }
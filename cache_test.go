@@ -1,8 +1,12 @@
 package cache2go
 
 import (
+	"bytes"
+	"container/heap"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -155,3 +159,809 @@ func TestCount(t *testing.T) {
 		t.Error("Error countiong entries: ", cache.Len())
 	}
 }
+
+func TestPeekDoesNotPromote(t *testing.T) {
+	cache := New(2, 0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	if v, ok := cache.Peek("a"); !ok || v != 1 {
+		t.Error("Error peeking cached key: ", v)
+	}
+	cache.Set("c", 3)
+	if cache.Contains("a") {
+		t.Error("peek should not have promoted the entry")
+	}
+	if keys := cache.Keys(); len(keys) != 2 || keys[0] != "c" || keys[1] != "b" {
+		t.Error("Error listing keys: ", keys)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	cache := New(0, 0)
+	view := cache.ReadOnly()
+	cache.Set("mama", "mere")
+	if v, ok := view.Get("mama"); !ok || v != "mere" {
+		t.Error("Error reading through view: ", v)
+	}
+	if !view.Contains("mama") || view.Len() != 1 || len(view.Keys()) != 1 {
+		t.Error("view does not reflect the underlying cache")
+	}
+	cache.Delete("mama")
+	if _, ok := view.Peek("mama"); ok {
+		t.Error("view does not reflect deletions")
+	}
+}
+
+func TestMinRetention(t *testing.T) {
+	cache := New(2, 0, WithMinRetention(time.Hour))
+	cache.Set("young", 1)
+	cache.Set("old", 2)
+	cache.Lock()
+	cache.cache["old"].Value.(*entry).timestamp = time.Now().Add(-2 * time.Hour)
+	cache.Unlock()
+	cache.Set("new", 3)
+	if !cache.Contains("young") || cache.Contains("old") {
+		t.Error("eviction should skip entries younger than the retention guard")
+	}
+	// every entry is now too young; the oldest one goes anyway
+	cache.Set("newer", 4)
+	if cache.Len() != 2 || cache.Contains("young") {
+		t.Error("error evicting when all entries are retained: ", cache.Keys())
+	}
+}
+
+func TestLockWaitHook(t *testing.T) {
+	var calls int64
+	cache := New(0, 0, WithLockWaitHook(func(wait time.Duration) {
+		if wait < 0 {
+			t.Error("negative lock wait: ", wait)
+		}
+		atomic.AddInt64(&calls, 1)
+	}))
+	cache.Set("mama", "mere")
+	cache.Get("mama")
+	cache.Len()
+	if n := atomic.LoadInt64(&calls); n != 3 {
+		t.Error("error observing lock waits: ", n)
+	}
+}
+
+func TestEntryInfo(t *testing.T) {
+	cache := New(0, time.Hour)
+	if _, ok := cache.EntryInfo("mama"); ok {
+		t.Error("Error getting info for missing key")
+	}
+	cache.Set("mama", "mere")
+	before, _ := cache.EntryInfo("mama")
+	time.Sleep(time.Millisecond)
+	cache.Get("mama")
+	info, ok := cache.EntryInfo("mama")
+	if !ok || info.Key != "mama" {
+		t.Error("Error getting entry info: ", info)
+	}
+	if !info.LastAccess.After(before.LastAccess) || !info.Timestamp.Equal(before.Timestamp) {
+		t.Error("Error tracking last access: ", info)
+	}
+	if !info.Expires.Equal(info.Timestamp.Add(time.Hour)) {
+		t.Error("Error computing expiration: ", info.Expires)
+	}
+}
+
+func TestOnEvicted(t *testing.T) {
+	cache := New(2, 0)
+	var evicted []string
+	cache.OnEvicted(func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	})
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Delete("b")
+	cache.Flush()
+	if fmt.Sprint(evicted) != "[a b c]" {
+		t.Error("Error calling eviction callback: ", evicted)
+	}
+}
+
+func TestEvictionBatch(t *testing.T) {
+	cache := New(8, 0, WithEvictionBatch(4))
+	var evicted []string
+	cache.OnEvicted(func(key string, value interface{}) {
+		// the lock is released before batched callbacks run
+		cache.Contains(key)
+		evicted = append(evicted, key)
+	})
+	for i := 0; i < 9; i++ {
+		cache.Set(fmt.Sprintf("%d", i), i)
+	}
+	if cache.Len() != 8 || fmt.Sprint(evicted) != "[0]" {
+		t.Error("evicted more than needed: ", cache.Len(), evicted)
+	}
+	weighted := NewBytesLimited(10*DefaultEntryOverhead, 0, WithEvictionBatch(4))
+	evicted = nil
+	weighted.OnEvicted(func(key string, value interface{}) {
+		weighted.Contains(key)
+		evicted = append(evicted, key)
+	})
+	for i := 0; i < 8; i++ {
+		weighted.Set(fmt.Sprint(i), "")
+	}
+	weighted.Set("big", string(make([]byte, 5*DefaultEntryOverhead)))
+	if fmt.Sprint(evicted) != "[0 1 2 3 4]" {
+		t.Error("error calling batched eviction callbacks: ", evicted)
+	}
+}
+
+func benchmarkEviction(b *testing.B, batch int) {
+	cache := New(1024, 0, WithEvictionBatch(batch))
+	cache.OnEvicted(func(key string, value interface{}) {
+		// simulate releasing an expensive resource
+		for i := 0; i < 100; i++ {
+			key = fmt.Sprint(len(key))
+		}
+	})
+	var worker int64
+	b.RunParallel(func(pb *testing.PB) {
+		id := atomic.AddInt64(&worker, 1)
+		i := 0
+		for pb.Next() {
+			cache.Set(fmt.Sprintf("%d-%d", id, i), i)
+			cache.Get(fmt.Sprintf("%d-%d", id, i/2))
+			i++
+		}
+	})
+}
+
+func BenchmarkEvictionInline(b *testing.B)  { benchmarkEviction(b, 0) }
+func BenchmarkEvictionBatch1(b *testing.B)  { benchmarkEviction(b, 1) }
+func BenchmarkEvictionBatch64(b *testing.B) { benchmarkEviction(b, 64) }
+
+func TestGetSet(t *testing.T) {
+	cache := New(0, 0)
+	if old, existed := cache.GetSet("mama", 1); existed || old != nil {
+		t.Error("Error swapping missing key: ", old)
+	}
+	if old, existed := cache.GetSet("mama", 2); !existed || old != 1 {
+		t.Error("Error swapping existing key: ", old)
+	}
+	if v, _ := cache.Get("mama"); v != 2 {
+		t.Error("Error storing swapped value: ", v)
+	}
+}
+
+func TestExpiringSoon(t *testing.T) {
+	cache := New(0, time.Hour)
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("%d", i), i)
+	}
+	// refreshing an entry moves it to the back of the expiry order
+	cache.Set("0", 0)
+	if keys := cache.ExpiringSoon(3); fmt.Sprint(keys) != "[1 2 3]" {
+		t.Error("Error listing soon expiring keys: ", keys)
+	}
+	if keys := cache.ExpiringSoon(20); len(keys) != 10 || keys[9] != "0" {
+		t.Error("Error listing all expiring keys: ", keys)
+	}
+	if keys := New(0, 0).ExpiringSoon(3); keys != nil {
+		t.Error("Error listing keys without expiration: ", keys)
+	}
+}
+
+func TestUninitializedCachePanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r != errUninitialized {
+			t.Error("expected a clear panic for a zero value cache, got: ", r)
+		}
+	}()
+	var cache Cache
+	cache.Set("mama", "mere")
+}
+
+func TestSetValidated(t *testing.T) {
+	errNil := errors.New("nil value")
+	cache := New(0, 0, WithValidator(func(key string, value interface{}) error {
+		if value.(*myStruct) == nil {
+			return errNil
+		}
+		return nil
+	}))
+	var missing *myStruct
+	if err := cache.SetValidated("mama", missing); err != errNil {
+		t.Error("Error validating value: ", err)
+	}
+	cache.Set("mama", missing)
+	if cache.Contains("mama") {
+		t.Error("invalid value should not be stored")
+	}
+	if err := cache.SetValidated("mama", &myStruct{data: "mere"}); err != nil || !cache.Contains("mama") {
+		t.Error("Error storing valid value: ", err)
+	}
+}
+
+func TestValidatedCopies(t *testing.T) {
+	validate := WithValidator(func(key string, value interface{}) error {
+		if value == nil {
+			return errors.New("nil value")
+		}
+		return nil
+	})
+	cache := New(0, 0, validate)
+	if old, existed := cache.GetSet("a", nil); old != nil || existed || cache.Contains("a") {
+		t.Error("GetSet stored an invalid value")
+	}
+	source := New(0, 0)
+	source.Set("a", nil)
+	source.Set("b", 2)
+	cache.Merge(source, nil)
+	if cache.Contains("a") || !cache.Contains("b") {
+		t.Error("Merge stored an invalid value: ", cache.Keys())
+	}
+	cache.Merge(source, func(a, b interface{}) interface{} { return nil })
+	if v, _ := cache.Get("b"); v != 2 {
+		t.Error("Merge stored an invalid merged value: ", v)
+	}
+	var buf bytes.Buffer
+	if err := source.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(0, 0, validate)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Contains("a") || !restored.Contains("b") {
+		t.Error("Load stored an invalid value: ", restored.Keys())
+	}
+}
+
+func TestLoadOrStore(t *testing.T) {
+	cache := New(1, 0)
+	if actual, loaded := cache.LoadOrStore("a", 1); loaded || actual != 1 {
+		t.Error("Error storing missing key: ", actual)
+	}
+	if actual, loaded := cache.LoadOrStore("a", 2); !loaded || actual != 1 {
+		t.Error("Error loading existing key: ", actual)
+	}
+	cache.LoadOrStore("b", 2)
+	if cache.Len() != 1 || cache.Contains("a") {
+		t.Error("LoadOrStore should respect maxEntries")
+	}
+}
+
+func TestSweepOnce(t *testing.T) {
+	cache := New(0, time.Hour)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Lock()
+	cache.ttlIndex[0].expires = time.Now()
+	cache.ttlIndex[1].expires = time.Now()
+	cache.Unlock()
+	if n := cache.SweepOnce(); n != 2 {
+		t.Error("Error sweeping expired entries: ", n)
+	}
+	if cache.Len() != 1 {
+		t.Error("Error sweeping expired entries: ", cache.Len())
+	}
+}
+
+func TestWithValue(t *testing.T) {
+	cache := New(1, 0)
+	var evicted []string
+	cache.OnEvicted(func(key string, value interface{}) {
+		evicted = append(evicted, fmt.Sprint(key, value))
+	})
+	cache.Set("counter", 1)
+	if cache.WithValue("missing", func(v interface{}) interface{} { return v }) {
+		t.Error("WithValue should report missing keys")
+	}
+	cache.WithValue("counter", func(v interface{}) interface{} {
+		// the entry is in use, so "other" is evicted instead
+		cache.Set("other", 0)
+		cache.Delete("counter")
+		if len(evicted) != 1 || evicted[0] != "other0" {
+			t.Error("in use entry should not be evicted: ", evicted)
+		}
+		return v.(int) + 1
+	})
+	if len(evicted) != 2 || evicted[1] != "counter2" {
+		t.Error("Error delaying eviction callback of an entry in use: ", evicted)
+	}
+}
+
+func TestWithValueConcurrent(t *testing.T) {
+	cache := New(0, 0)
+	cache.Set("counter", 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 2000; j++ {
+				cache.WithValue("counter", func(v interface{}) interface{} { return v.(int) + 1 })
+			}
+		}()
+	}
+	wg.Wait()
+	if v, _ := cache.Get("counter"); v != 16000 {
+		t.Error("concurrent WithValue calls lost updates: ", v)
+	}
+}
+
+func TestGetWithRelease(t *testing.T) {
+	cache := New(1, 0)
+	var recycled []interface{}
+	cache.OnEvicted(func(key string, value interface{}) {
+		recycled = append(recycled, value)
+	})
+	if _, _, ok := cache.GetWithRelease("a"); ok {
+		t.Error("GetWithRelease should report missing keys")
+	}
+	cache.Set("a", 1)
+	v, release, ok := cache.GetWithRelease("a")
+	if !ok || v != 1 {
+		t.Fatal("Error getting held value: ", v, ok)
+	}
+	_, release2, _ := cache.GetWithRelease("a")
+	cache.Delete("a")
+	release()
+	release()
+	if len(recycled) != 0 {
+		t.Error("value recycled while still held: ", recycled)
+	}
+	release2()
+	if len(recycled) != 1 || recycled[0] != 1 {
+		t.Error("Error recycling value after the last release: ", recycled)
+	}
+}
+
+func TestOnSweep(t *testing.T) {
+	cache := New(0, 5*time.Millisecond)
+	defer cache.Close()
+	sweeps := make(chan int, 100)
+	cache.OnSweep(func(removed int) {
+		sweeps <- removed
+		panic("sweep callback")
+	})
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	total := 0
+	timeout := time.After(time.Second)
+	for total < 2 {
+		select {
+		case n := <-sweeps:
+			total += n
+		case <-timeout:
+			t.Fatal("sweeps reported ", total, " removed entries")
+		}
+	}
+	if total != 2 {
+		t.Error("Error counting swept entries: ", total)
+	}
+}
+
+func TestLenByState(t *testing.T) {
+	cache := New(0, time.Hour)
+	defer cache.Close()
+	for i := 0; i < 10; i++ {
+		cache.SetWithTTL(fmt.Sprint(i), i, time.Duration(i+1)*time.Hour)
+	}
+	cache.Set("forever", 0)
+	cache.Lock()
+	for i := 0; i < 4; i++ {
+		en := cache.cache[fmt.Sprint(i)].Value.(*entry)
+		en.expires = time.Now().Add(-time.Minute)
+		heap.Fix(&cache.ttlIndex, en.index)
+	}
+	cache.Unlock()
+	if live, expired := cache.LenByState(); live != 7 || expired != 4 {
+		t.Error("Error counting entries by state: ", live, expired)
+	}
+}
+
+func TestGetTTL(t *testing.T) {
+	cache := New(0, 0)
+	cache.SetWithTTL("a", 1, time.Hour)
+	cache.Set("forever", 2)
+	if d, ok := cache.GetTTL("a"); !ok || d <= 59*time.Minute || d > time.Hour {
+		t.Error("Error getting remaining lifetime: ", d, ok)
+	}
+	if d, ok := cache.GetTTL("forever"); !ok || d != NoExpiration {
+		t.Error("Error getting lifetime of entry without expiration: ", d, ok)
+	}
+	if _, ok := cache.GetTTL("missing"); ok {
+		t.Error("GetTTL should report missing keys")
+	}
+	cache.Lock()
+	cache.cache["a"].Value.(*entry).expires = time.Now().Add(-time.Second)
+	cache.Unlock()
+	if d, ok := cache.GetTTL("a"); !ok || d > 0 {
+		t.Error("Error getting lifetime of expired entry: ", d, ok)
+	}
+	if keys := cache.Keys(); keys[0] != "forever" {
+		t.Error("GetTTL changed the recency: ", keys)
+	}
+	cache.Close()
+}
+
+func TestOnExpiredBatch(t *testing.T) {
+	cache := New(0, 0)
+	defer cache.Close()
+	var evicted []string
+	cache.OnEvicted(func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	})
+	batches := make(chan []Event, 10)
+	cache.OnExpiredBatch(func(events []Event) {
+		batches <- events
+	})
+	for i := 0; i < 50; i++ {
+		cache.SetWithTTL(fmt.Sprint(i), i, 10*time.Millisecond)
+	}
+	cache.Set("kept", 0)
+	cache.Delete("kept")
+	total := 0
+	timeout := time.After(time.Second)
+	for total < 50 {
+		select {
+		case events := <-batches:
+			total += len(events)
+			if events[0].Expires.IsZero() {
+				t.Error("Error describing expired entry: ", events[0])
+			}
+		case <-timeout:
+			t.Fatal("expired entries batched: ", total)
+		}
+	}
+	cache.Lock()
+	defer cache.Unlock()
+	if len(evicted) != 1 || evicted[0] != "kept" {
+		t.Error("batched entries also went through OnEvicted: ", evicted)
+	}
+}
+
+func TestExpiryOrderAtFixedClock(t *testing.T) {
+	cache := New(0, 0)
+	defer cache.Close()
+	now := time.Now()
+	cache.clock = func() time.Time { return now }
+	var want []string
+	for i := 0; i < 200; i++ {
+		cache.SetWithTTL(fmt.Sprint(i), i, time.Minute)
+		want = append(want, fmt.Sprint(i))
+	}
+	// setting a key again moves it after the others
+	cache.SetWithTTL("0", 0, time.Minute)
+	want = append(want[1:], "0")
+	if got := cache.ExpiringSoon(200); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Error("Error ordering entries set at the same time: ", got)
+	}
+	var expired []string
+	cache.OnEvicted(func(key string, value interface{}) {
+		expired = append(expired, key)
+	})
+	cache.Lock()
+	now = now.Add(time.Minute)
+	cache.Unlock()
+	cache.SweepOnce()
+	if fmt.Sprint(expired) != fmt.Sprint(want) {
+		t.Error("Error expiring entries set at the same time in order: ", expired)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	cache := New(0, time.Hour)
+	defer cache.Close()
+	evicted := 0
+	cache.OnEvicted(func(key string, value interface{}) { evicted++ })
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("stale", 3)
+	cache.Lock()
+	cache.cache["stale"].Value.(*entry).expires = time.Now()
+	cache.Unlock()
+	items := cache.Drain()
+	if len(items) != 2 || items["a"] != 1 || items["b"] != 2 {
+		t.Error("Error draining live entries: ", items)
+	}
+	if cache.Len() != 0 || evicted != 0 {
+		t.Error("Error emptying the cache without callbacks: ", cache.Len(), evicted)
+	}
+	cache.Set("c", 3)
+	if v, _ := cache.Get("c"); v != 3 {
+		t.Error("cache unusable after Drain")
+	}
+}
+
+func TestSetExpiration(t *testing.T) {
+	cache := New(0, 0)
+	cache.Set("a", 1)
+	cache.SetExpiration(time.Millisecond)
+	cache.SetExpiration(time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if cache.Len() != 0 {
+		t.Error("Error expiring entries after enabling expiration: ", cache.Len())
+	}
+	cache.Set("b", 2)
+	cache.SetExpiration(0)
+	time.Sleep(5 * time.Millisecond)
+	if !cache.Contains("b") {
+		t.Error("Error disabling expiration")
+	}
+	cache.SetExpiration(time.Hour)
+	if info, _ := cache.EntryInfo("b"); !info.Expires.Equal(info.Timestamp.Add(time.Hour)) {
+		t.Error("Error recomputing expiration: ", info)
+	}
+}
+
+func TestRename(t *testing.T) {
+	cache := New(0, time.Hour)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	before, _ := cache.EntryInfo("a")
+	if cache.Rename("missing", "d") {
+		t.Error("Rename should fail for missing keys")
+	}
+	if !cache.Rename("a", "c") {
+		t.Error("Error renaming key")
+	}
+	after, _ := cache.EntryInfo("c")
+	if v, _ := cache.Peek("c"); v != 1 || !after.Timestamp.Equal(before.Timestamp) {
+		t.Error("Error preserving renamed entry: ", v, after)
+	}
+	if keys := cache.Keys(); fmt.Sprint(keys) != "[b c]" || cache.Contains("a") {
+		t.Error("Error preserving recency of renamed entry: ", keys)
+	}
+}
+
+func TestExpiryProfile(t *testing.T) {
+	cache := New(0, time.Hour)
+	for i := 0; i < 6; i++ {
+		cache.Set(fmt.Sprintf("%d", i), i)
+	}
+	cache.Lock()
+	now := time.Now()
+	for i, en := range cache.ttlIndex {
+		en.expires = now.Add(time.Duration(i-1) * time.Minute)
+	}
+	cache.Unlock()
+	// expires in -1m, 0m, 1m, 2m, 3m, 4m
+	profile := cache.ExpiryProfile([]time.Duration{time.Minute, 3 * time.Minute})
+	if fmt.Sprint(profile) != "[1 2 1 2]" {
+		t.Error("Error profiling expirations: ", profile)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	cache := New(0, time.Hour)
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("%d", i), i)
+	}
+	cache.Lock()
+	for _, en := range cache.ttlIndex {
+		en.expires = time.Now()
+	}
+	cache.Unlock()
+	cache.Set("live", 1)
+	remaining, removed := cache.Compact()
+	if remaining != 1 || removed != 1000 {
+		t.Error("Error compacting cache: ", remaining, removed)
+	}
+	if cap(cache.ttlIndex) > 2 {
+		t.Error("Error releasing TTL index capacity: ", cap(cache.ttlIndex))
+	}
+	if v, ok := cache.Get("live"); !ok || v != 1 {
+		t.Error("Error keeping live entries: ", v)
+	}
+}
+
+func TestAccessCount(t *testing.T) {
+	cache := New(0, 0)
+	if _, ok := cache.AccessCount("mama"); ok {
+		t.Error("Error counting accesses of missing key")
+	}
+	cache.Set("mama", 1)
+	cache.Get("mama")
+	cache.Get("mama")
+	cache.Peek("mama")
+	cache.Set("mama", 2)
+	if n, ok := cache.AccessCount("mama"); !ok || n != 2 {
+		t.Error("Error counting accesses: ", n)
+	}
+	cache.Delete("mama")
+	cache.Set("mama", 3)
+	if info, _ := cache.EntryInfo("mama"); info.AccessCount != 0 {
+		t.Error("Error resetting accesses of new entry: ", info.AccessCount)
+	}
+}
+
+func TestMostAndLeastRecent(t *testing.T) {
+	cache := New(0, 0)
+	for i := 0; i < 5; i++ {
+		cache.Set(fmt.Sprintf("%d", i), i)
+	}
+	cache.Get("0")
+	if v := cache.MostRecent(2); fmt.Sprint(v) != "[0 4]" {
+		t.Error("Error listing most recent values: ", v)
+	}
+	if v := cache.LeastRecent(2); fmt.Sprint(v) != "[1 2]" {
+		t.Error("Error listing least recent values: ", v)
+	}
+	if v := cache.LeastRecent(10); len(v) != 5 {
+		t.Error("Error listing more values than cached: ", v)
+	}
+	if keys := cache.Keys(); keys[0] != "0" {
+		t.Error("listing values should not update recency: ", keys)
+	}
+}
+
+func TestPanickingCallbacks(t *testing.T) {
+	var recovered int64
+	cache := New(1, time.Millisecond, WithPanicHandler(func(r interface{}) {
+		atomic.AddInt64(&recovered, 1)
+	}))
+	cache.OnEvicted(func(key string, value interface{}) {
+		panic("boom")
+	})
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	if cache.Len() != 1 || atomic.LoadInt64(&recovered) != 1 {
+		t.Error("panicking callback should not leave the cache locked")
+	}
+	// the cleanup goroutine survives the panics of expirations
+	time.Sleep(20 * time.Millisecond)
+	cache.Set("c", 3)
+	time.Sleep(20 * time.Millisecond)
+	if cache.Len() != 0 || atomic.LoadInt64(&recovered) != 3 {
+		t.Error("cleanup should survive panicking callbacks: ", cache.Len())
+	}
+}
+
+func TestPanickingValidator(t *testing.T) {
+	cache := New(0, 0, WithValidator(func(key string, value interface{}) error {
+		panic("boom")
+	}))
+	if err := cache.SetValidated("a", 1); err != errValidatorPanicked {
+		t.Error("Error reporting panicking validator: ", err)
+	}
+}
+
+func TestPanickingWithValue(t *testing.T) {
+	cache := New(0, 0)
+	cache.Set("a", 1)
+	func() {
+		defer func() { recover() }()
+		cache.WithValue("a", func(v interface{}) interface{} { panic("boom") })
+	}()
+	cache.WithValue("a", func(v interface{}) interface{} { return 2 })
+	if n := cache.cache["a"].Value.(*entry).refs; n != 0 {
+		t.Error("panicking WithValue should release the entry: ", n)
+	}
+}
+
+func TestGetManyStale(t *testing.T) {
+	cache := New(0, time.Hour)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Lock()
+	cache.cache["b"].Value.(*entry).expires = time.Now()
+	cache.Unlock()
+	fresh, stale := cache.GetManyStale([]string{"a", "b", "c"})
+	if len(fresh) != 1 || fresh["a"] != 1 {
+		t.Error("Error getting fresh values: ", fresh)
+	}
+	if len(stale) != 1 || stale["b"] != 2 {
+		t.Error("Error getting stale values: ", stale)
+	}
+	if cache.Contains("b") || cache.Len() != 2 {
+		t.Error("stale values should be hidden but kept in place")
+	}
+	view := cache.ReadOnly()
+	if keys := view.Keys(); len(keys) != 1 || keys[0] != "a" {
+		t.Error("Keys should hide stale entries: ", keys)
+	}
+	if len(cache.MostRecent(2)) != 1 || len(cache.LeastRecent(2)) != 1 {
+		t.Error("MostRecent and LeastRecent should hide stale entries")
+	}
+	if _, ok := cache.Get("b"); ok || cache.Len() != 1 {
+		t.Error("Get should remove expired entries")
+	}
+}
+
+func TestOverflowReject(t *testing.T) {
+	cache := New(2, 0, WithOverflowPolicy(OverflowReject))
+	cache.Set("a", 1)
+	if !cache.SetWithResult("b", 2) {
+		t.Error("Error storing value in cache with room")
+	}
+	if cache.SetWithResult("c", 3) || cache.Contains("c") {
+		t.Error("full cache should reject new keys")
+	}
+	if !cache.SetWithResult("a", 4) {
+		t.Error("full cache should accept updates")
+	}
+	if actual, loaded := cache.LoadOrStore("d", 5); loaded || actual != nil {
+		t.Error("Error reporting rejected LoadOrStore: ", actual)
+	}
+	if cache.Len() != 2 || !cache.Contains("b") {
+		t.Error("rejecting keys should not evict: ", cache.Keys())
+	}
+}
+
+func TestReplaceAll(t *testing.T) {
+	cache := New(0, 0)
+	var evicted []string
+	cache.OnEvicted(func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	})
+	cache.Set("old", 1)
+	cache.Set("kept", 2)
+	cache.ReplaceAll(map[string]interface{}{"kept": 3, "new": 4})
+	if fmt.Sprint(evicted) != "[old kept]" {
+		t.Error("Error purging old entries: ", evicted)
+	}
+	if v, _ := cache.Get("kept"); v != 3 || cache.Len() != 2 || cache.Contains("old") {
+		t.Error("Error replacing entries: ", cache.Keys())
+	}
+}
+
+func TestSoftLimit(t *testing.T) {
+	var warnings []int
+	cache := New(0, 0, WithSoftLimit(2, func(n int) {
+		warnings = append(warnings, n)
+	}))
+	for i := 0; i < 5; i++ {
+		cache.Set(fmt.Sprintf("%d", i), i)
+	}
+	if fmt.Sprint(warnings) != "[3]" || cache.Len() != 5 {
+		t.Error("Error warning once about the soft limit: ", warnings)
+	}
+	cache.Flush()
+	for i := 0; i < 3; i++ {
+		cache.Set(fmt.Sprintf("%d", i), i)
+	}
+	if fmt.Sprint(warnings) != "[3 3]" {
+		t.Error("Error warning again after shrinking: ", warnings)
+	}
+}
+
+func TestAdaptiveTTL(t *testing.T) {
+	cache := New(0, time.Minute, WithAdaptiveTTL(0.5, 2*time.Minute))
+	cache.Set("hot", 1)
+	cache.Set("cold", 2)
+	cache.Get("hot")
+	hot, _ := cache.EntryInfo("hot")
+	if got := hot.Expires.Sub(hot.Timestamp); got != 90*time.Second {
+		t.Error("Error extending TTL of accessed entry: ", got)
+	}
+	for i := 0; i < 10; i++ {
+		cache.Get("hot")
+	}
+	hot, _ = cache.EntryInfo("hot")
+	if got := hot.Expires.Sub(hot.Timestamp); got != 2*time.Minute {
+		t.Error("Error bounding extended TTL: ", got)
+	}
+	cold, _ := cache.EntryInfo("cold")
+	if got := cold.Expires.Sub(cold.Timestamp); got != time.Minute {
+		t.Error("cold entries should keep their TTL: ", got)
+	}
+	if keys := cache.ExpiringSoon(1); keys[0] != "cold" {
+		t.Error("Error reordering TTL index: ", keys)
+	}
+}
+
+func TestGetWithRank(t *testing.T) {
+	cache := New(0, 0)
+	for i := 0; i < 5; i++ {
+		cache.Set(fmt.Sprintf("%d", i), i)
+	}
+	if v, rank, ok := cache.GetWithRank("0"); !ok || v != 0 || rank != 4 {
+		t.Error("Error ranking least recently used entry: ", rank)
+	}
+	if _, rank, _ := cache.GetWithRank("0"); rank != 0 {
+		t.Error("Error ranking most recently used entry: ", rank)
+	}
+	if _, _, ok := cache.GetWithRank("missing"); ok {
+		t.Error("Error ranking missing key")
+	}
+}
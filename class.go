@@ -0,0 +1,81 @@
+package cache2go
+
+// WithClassLimits bounds the number of entries of each class, as
+// tagged by SetWithClass, by limits[class]. A class over its limit
+// evicts its own least recently used entries, leaving the other
+// classes alone, so one tenant churning through keys cannot push out
+// those of another. Classes not in limits are only bound by
+// maxEntries, which still evicts across all the classes.
+func WithClassLimits(limits map[string]int) Option {
+	return func(c *Cache) {
+		c.classLimits = limits
+	}
+}
+
+// SetWithClass adds a value to the cache like Set, tagging the entry
+// with class. Each class keeps its own recency list, shared with the
+// rest of the cache through the same map, so that entries can be
+// evicted per class under WithClassLimits. Setting the key again
+// with Set keeps its class; an empty class removes the tag.
+func (c *Cache) SetWithClass(key string, value interface{}, class string) {
+	if c.validateValue(key, value) != nil {
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	if _, _, stored := c.set(key, value, 1, 0); !stored {
+		return
+	}
+	e, ok := c.cache[key]
+	if !ok {
+		return
+	}
+	en := e.Value.(*entry)
+	if en.class != class {
+		c.unclass(en)
+		en.class = class
+		if class != "" {
+			if c.classes == nil {
+				c.classes = make(map[string]*lruList)
+			}
+			l := c.classes[class]
+			if l == nil {
+				l = newLRUList()
+				c.classes[class] = l
+			}
+			en.classElem = l.PushFront(en)
+		}
+	}
+	if limit := c.classLimits[class]; class != "" && limit > 0 {
+		for l := c.classes[class]; l.Len() > limit; {
+			n := l.Len()
+			c.evict(c.leastRecent(l, en), en)
+			if l.Len() == n {
+				break
+			}
+		}
+	}
+}
+
+// ClassLen returns the number of entries tagged with class.
+func (c *Cache) ClassLen(class string) int {
+	c.rlock()
+	defer c.RUnlock()
+	if l := c.classes[class]; l != nil {
+		return l.Len()
+	}
+	return 0
+}
+
+// unclass removes en from the recency list of its class.
+func (c *Cache) unclass(en *entry) {
+	if en.classElem == nil {
+		return
+	}
+	l := c.classes[en.class]
+	l.Remove(en.classElem)
+	en.classElem = nil
+	if l.Len() == 0 {
+		delete(c.classes, en.class)
+	}
+}
@@ -0,0 +1,36 @@
+package cache2go
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSetWithClass(t *testing.T) {
+	cache := New(10, 0, WithClassLimits(map[string]int{"noisy": 2}))
+	cache.SetDebug(true)
+	var evicted []string
+	cache.OnEvicted(func(key string, value interface{}) { evicted = append(evicted, key) })
+	cache.SetWithClass("quiet", 1, "calm")
+	cache.Set("plain", 1)
+	for i := 0; i < 5; i++ {
+		cache.SetWithClass(fmt.Sprint("noisy", i), i, "noisy")
+	}
+	if cache.Len() != 4 || !cache.Contains("quiet") || !cache.Contains("plain") {
+		t.Error("class limit evicted other classes: ", cache.Keys())
+	}
+	if fmt.Sprint(evicted) != "[noisy0 noisy1 noisy2]" || cache.ClassLen("noisy") != 2 {
+		t.Error("Error evicting within the class: ", evicted, cache.ClassLen("noisy"))
+	}
+	// reads and plain updates keep the class and refresh its recency
+	cache.Get("noisy3")
+	cache.Set("noisy3", 3)
+	cache.SetWithClass("noisy5", 5, "noisy")
+	if !cache.Contains("noisy3") || cache.Contains("noisy4") {
+		t.Error("Error evicting least recently used of the class: ", cache.Keys())
+	}
+	cache.SetWithClass("quiet", 2, "")
+	cache.Delete("noisy3")
+	if cache.ClassLen("calm") != 0 || cache.ClassLen("noisy") != 1 {
+		t.Error("Error untagging entries: ", cache.ClassLen("calm"), cache.ClassLen("noisy"))
+	}
+}
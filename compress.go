@@ -0,0 +1,120 @@
+package cache2go
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+)
+
+// Compressor compresses the byte values stored with SetBytes.
+type Compressor interface {
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// Gzip is a Compressor using compress/gzip.
+var Gzip Compressor = gzipCompressor{}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// WithCompression makes SetBytes compress values of at least
+// threshold bytes with comp, or with Gzip if comp is nil.
+func WithCompression(threshold int, comp Compressor) Option {
+	if comp == nil {
+		comp = Gzip
+	}
+	return func(c *Cache) {
+		c.compressor = comp
+		c.compressOver = threshold
+	}
+}
+
+// SetBytes adds a byte value to the cache, compressing it first if
+// the cache was created WithCompression and the value is large
+// enough. Values that fail to compress are stored as they are.
+// Compressed values must be read back with GetBytes: Get, Await,
+// WithValue and the OnEvicted callback see the compressed bytes,
+// while the overflow store and the second tier of a Tiered cache
+// receive them decompressed. Save and Load, Merge and Partition keep
+// them compressed if the receiving cache was created WithCompression
+// too, and decompress them otherwise: Load with Gzip, Merge and
+// Partition with the compressor of the source cache.
+func (c *Cache) SetBytes(key string, value []byte) {
+	if c.validateValue(key, value) != nil {
+		return
+	}
+	compressed := false
+	if c.compressor != nil && len(value) >= c.compressOver {
+		if data, err := c.compressor.Compress(value); err == nil {
+			value, compressed = data, true
+		}
+	}
+	c.lock()
+	defer c.unlock()
+	if _, _, stored := c.set(key, value, 1, 0); stored && compressed {
+		c.cache[key].Value.(*entry).compressed = true
+	}
+}
+
+// GetBytes looks up a byte value from the cache, decompressing it
+// if it was stored compressed. Values that are not []byte or fail to
+// decompress are reported as missing.
+func (c *Cache) GetBytes(key string) ([]byte, bool) {
+	c.lock()
+	value, ok := c.get(key)
+	compressed := ok && c.cache[key].Value.(*entry).compressed
+	c.unlock()
+	data, isBytes := value.([]byte)
+	if !ok || !isBytes {
+		return nil, false
+	}
+	if compressed {
+		var err error
+		if data, err = c.compressor.Decompress(data); err != nil {
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// inflate decompresses with comp a value compressed by SetBytes if
+// the cache does not compress, returning the value to store, whether
+// it is still compressed and false if it failed to decompress.
+func (c *Cache) inflate(value interface{}, compressed bool, comp Compressor) (interface{}, bool, bool) {
+	if !compressed || c.compressor != nil {
+		return value, compressed, true
+	}
+	data, ok := decompress(value, comp)
+	return data, false, ok
+}
+
+// decompress decompresses with comp a value compressed by SetBytes.
+func decompress(value interface{}, comp Compressor) ([]byte, bool) {
+	data, isBytes := value.([]byte)
+	if !isBytes || comp == nil {
+		return nil, false
+	}
+	data, err := comp.Decompress(data)
+	return data, err == nil
+}
@@ -0,0 +1,106 @@
+package cache2go
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCompression(t *testing.T) {
+	cache := New(0, 0, WithCompression(64, nil))
+	small := []byte("mama are mere")
+	large := bytes.Repeat([]byte("mama are mere "), 100)
+	cache.SetBytes("small", small)
+	cache.SetBytes("large", large)
+	if v, _ := cache.Peek("small"); !bytes.Equal(v.([]byte), small) {
+		t.Error("small values should not be compressed")
+	}
+	if v, _ := cache.Peek("large"); len(v.([]byte)) >= len(large) {
+		t.Error("large values should be compressed: ", len(v.([]byte)))
+	}
+	if v, ok := cache.GetBytes("large"); !ok || !bytes.Equal(v, large) {
+		t.Error("Error decompressing value")
+	}
+	if v, ok := cache.GetBytes("small"); !ok || !bytes.Equal(v, small) {
+		t.Error("Error reading uncompressed value")
+	}
+	cache.Set("large", small)
+	if v, ok := cache.GetBytes("large"); !ok || !bytes.Equal(v, small) {
+		t.Error("Set should clear the compressed flag")
+	}
+	cache.Set("other", 1)
+	if _, ok := cache.GetBytes("other"); ok {
+		t.Error("GetBytes should report non byte values as missing")
+	}
+}
+
+func TestCompressionCopies(t *testing.T) {
+	large := bytes.Repeat([]byte("mama are mere "), 100)
+	cache := New(0, 0, WithCompression(64, nil))
+	cache.SetBytes("a", large)
+	var buf bytes.Buffer
+	if err := cache.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(0, 0, WithCompression(64, nil))
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := restored.GetBytes("a"); !ok || !bytes.Equal(v, large) {
+		t.Error("Error decompressing restored value")
+	}
+	merged := New(0, 0, WithCompression(64, nil))
+	merged.Merge(cache, nil)
+	if v, ok := merged.GetBytes("a"); !ok || !bytes.Equal(v, large) {
+		t.Error("Error decompressing merged value")
+	}
+
+	plain := New(0, 0)
+	buf.Reset()
+	if err := cache.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := plain.GetBytes("a"); !ok || !bytes.Equal(v, large) {
+		t.Error("Error decompressing value restored into a plain cache")
+	}
+	plain = New(0, 0)
+	plain.Merge(cache, nil)
+	if v, ok := plain.Get("a"); !ok || !bytes.Equal(v.([]byte), large) {
+		t.Error("Error decompressing value merged into a plain cache")
+	}
+	plain.Set("a", []byte("plain"))
+	time.Sleep(time.Millisecond)
+	newer := New(0, 0, WithCompression(64, nil))
+	newer.SetBytes("a", large)
+	plain.Merge(newer, nil)
+	if v, ok := plain.GetBytes("a"); !ok || !bytes.Equal(v, large) {
+		t.Error("Error decompressing newer value merged into a plain cache")
+	}
+	in, _ := cache.Partition(func(string, interface{}) bool { return true })
+	if v, ok := in.GetBytes("a"); !ok || !bytes.Equal(v, large) {
+		t.Error("Error decompressing partitioned value")
+	}
+	spilled := &mapStore{m: make(map[string]interface{})}
+	plain = New(1, 0, WithOverflowStore(spilled))
+	plain.Merge(cache, nil)
+	plain.Set("b", 2)
+	if v, _ := spilled.m["a"].([]byte); !bytes.Equal(v, large) {
+		t.Error("Error spilling value merged into a plain cache")
+	}
+
+	cache.WithValue("a", func(v interface{}) interface{} { return []byte("plain") })
+	if v, ok := cache.GetBytes("a"); !ok || string(v) != "plain" {
+		t.Error("WithValue should clear the compressed flag: ", v, ok)
+	}
+
+	store := &mapStore{m: make(map[string]interface{})}
+	spilling := New(1, 0, WithCompression(64, nil), WithOverflowStore(store))
+	spilling.SetBytes("a", large)
+	spilling.Set("b", 2)
+	if v, _ := store.m["a"].([]byte); !bytes.Equal(v, large) {
+		t.Error("Error decompressing spilled value")
+	}
+}
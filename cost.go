@@ -0,0 +1,35 @@
+package cache2go
+
+// WithCostAwareEviction makes the cache evict the entry with the
+// lowest GreedyDual score instead of the least recently used one.
+// An entry scores its cost, as given to SetWithCost, plus the score
+// of the last evicted entry at the time it was last set or read, so
+// expensive entries survive longer while entries nobody reads sink
+// over time. Set stores values with a cost of 1.
+func WithCostAwareEviction() Option {
+	return func(c *Cache) {
+		c.policy = policyCost
+		c.evictIndex.less = costLess
+	}
+}
+
+// costLess orders entries by score, ties going to the least
+// recently used entry.
+func costLess(a, b *entry) bool {
+	if a.score == b.score {
+		return a.lastAccess.Before(b.lastAccess)
+	}
+	return a.score < b.score
+}
+
+// SetWithCost adds a value to the cache along with what it costs
+// to recompute it. The cost only matters for caches created
+// WithCostAwareEviction.
+func (c *Cache) SetWithCost(key string, value interface{}, cost float64) {
+	if c.validateValue(key, value) != nil {
+		return
+	}
+	c.lock()
+	c.set(key, value, cost, 0)
+	c.unlock()
+}
@@ -0,0 +1,27 @@
+package cache2go
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCostAwareEviction(t *testing.T) {
+	cache := New(3, 0, WithCostAwareEviction())
+	cache.SetWithCost("expensive", 1, 10)
+	cache.Set("a", 2)
+	cache.Set("b", 3)
+	// the expensive entry is the least recently used, but the
+	// cheap ones are evicted first
+	cache.Set("c", 4)
+	cache.Set("d", 5)
+	if !cache.Contains("expensive") || cache.Len() != 3 {
+		t.Error("Error keeping expensive entry: ", cache.Keys())
+	}
+	// inflation eventually catches up with an unused entry
+	for i := 0; i < 40; i++ {
+		cache.Set(fmt.Sprintf("%d", i), i)
+	}
+	if cache.Contains("expensive") {
+		t.Error("Error aging expensive entry out")
+	}
+}
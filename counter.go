@@ -0,0 +1,102 @@
+package cache2go
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// counterPromotion is how often increments refresh the recency of a
+// counter.
+const counterPromotion = time.Second
+
+// CounterCache is a Cache of int64 counters. Incrementing an existing
+// counter only takes the read lock and updates it atomically, so
+// increments of many keys proceed in parallel. Counters expire and
+// are evicted like any entry, but increments refresh their recency at
+// most once per second, which makes the LRU order approximate.
+type CounterCache struct {
+	c *Cache
+}
+
+type counter struct {
+	n int64
+	// promoted is when the recency was last refreshed, in Unix
+	// nanoseconds.
+	promoted int64
+}
+
+// NewCounterCache creates a counter cache with the same arguments as
+// New.
+func NewCounterCache(maxEntries int, expire time.Duration, opts ...Option) *CounterCache {
+	return &CounterCache{c: New(maxEntries, expire, opts...)}
+}
+
+// Increment adds delta to the counter stored under key, creating it
+// at zero first if needed, and returns the new count. A counter the
+// cache does not store, because it is full under OverflowReject or
+// evicts the counter right away, is not created and Increment
+// returns 0 for it.
+func (cc *CounterCache) Increment(key string, delta int64) int64 {
+	c := cc.c
+	now := c.now()
+	c.rlock()
+	if e, ok := c.cache[key]; ok && !c.expired(e.Value.(*entry), now) {
+		ctr := e.Value.(*entry).value.(*counter)
+		n := atomic.AddInt64(&ctr.n, delta)
+		stale := now.UnixNano()-atomic.LoadInt64(&ctr.promoted) >= int64(counterPromotion)
+		c.RUnlock()
+		if stale {
+			cc.promote(key, ctr, now)
+		}
+		return n
+	}
+	c.RUnlock()
+
+	c.lock()
+	defer c.unlock()
+	if e := c.live(key, now); e != nil {
+		ctr := e.Value.(*entry).value.(*counter)
+		c.access(e)
+		atomic.StoreInt64(&ctr.promoted, now.UnixNano())
+		return atomic.AddInt64(&ctr.n, delta)
+	}
+	if _, _, stored := c.set(key, &counter{n: delta, promoted: now.UnixNano()}, 1, 0); !stored {
+		return 0
+	}
+	return delta
+}
+
+// Decrement subtracts delta from the counter stored under key, like
+// Increment with -delta.
+func (cc *CounterCache) Decrement(key string, delta int64) int64 {
+	return cc.Increment(key, -delta)
+}
+
+// promote refreshes the recency of ctr if it is still stored under
+// key.
+func (cc *CounterCache) promote(key string, ctr *counter, now time.Time) {
+	c := cc.c
+	c.lock()
+	defer c.unlock()
+	if e, ok := c.cache[key]; ok && e.Value.(*entry).value == ctr {
+		c.access(e)
+		atomic.StoreInt64(&ctr.promoted, now.UnixNano())
+	}
+}
+
+// Get returns the count stored under key.
+func (cc *CounterCache) Get(key string) (int64, bool) {
+	if v, ok := cc.c.Get(key); ok {
+		return atomic.LoadInt64(&v.(*counter).n), true
+	}
+	return 0, false
+}
+
+// Delete removes the counter stored under key.
+func (cc *CounterCache) Delete(key string) { cc.c.Delete(key) }
+
+// Len returns the number of counters.
+func (cc *CounterCache) Len() int { return cc.c.Len() }
+
+// Close stops the background goroutines of the cache.
+func (cc *CounterCache) Close() { cc.c.Close() }
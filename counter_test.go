@@ -0,0 +1,70 @@
+package cache2go
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCounterCache(t *testing.T) {
+	cc := NewCounterCache(2, 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				cc.Increment("a", 2)
+				cc.Decrement("a", 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n, ok := cc.Get("a"); !ok || n != 8000 {
+		t.Error("Error counting concurrent increments: ", n)
+	}
+	cc.Increment("b", 1)
+	cc.Increment("c", 1)
+	if _, ok := cc.Get("a"); ok || cc.Len() != 2 {
+		t.Error("Error evicting counters")
+	}
+	cc.Delete("b")
+	if n := cc.Increment("b", 5); n != 5 {
+		t.Error("Error restarting deleted counter: ", n)
+	}
+
+	cc = NewCounterCache(1, 0, WithOverflowPolicy(OverflowReject))
+	cc.Increment("a", 1)
+	if n := cc.Increment("b", 1); n != 0 || cc.Len() != 1 {
+		t.Error("Error reporting rejected counter: ", n)
+	}
+	if _, ok := cc.Get("b"); ok {
+		t.Error("rejected counter stored")
+	}
+}
+
+func BenchmarkCounterCache(b *testing.B) {
+	cc := NewCounterCache(0, 0)
+	var next uint64
+	b.RunParallel(func(pb *testing.PB) {
+		key := fmt.Sprint(atomic.AddUint64(&next, 1) % 8)
+		for pb.Next() {
+			cc.Increment(key, 1)
+		}
+	})
+}
+
+func BenchmarkCounterWithValue(b *testing.B) {
+	cache := New(0, 0)
+	for i := 0; i < 8; i++ {
+		cache.Set(fmt.Sprint(i), int64(0))
+	}
+	var next uint64
+	b.RunParallel(func(pb *testing.PB) {
+		key := fmt.Sprint(atomic.AddUint64(&next, 1) % 8)
+		for pb.Next() {
+			cache.WithValue(key, func(v interface{}) interface{} { return v.(int64) + 1 })
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package cache2go
+
+import "fmt"
+
+// SetDebug turns on or off checking the internal invariants of the
+// cache, as Verify does, after every operation that modifies it,
+// panicking with the error. It is meant for tests: the checks take
+// time proportional to the size of the cache.
+func (c *Cache) SetDebug(on bool) {
+	c.lock()
+	c.debug = on
+	c.unlock()
+}
+
+// Verify checks the internal invariants of the cache: every entry of
+// the map is in the recency list and the reverse, the class lists
+// only hold cached entries of their class, and the expiration and
+// eviction indexes hold exactly the entries they should in heap
+// order. It returns an error describing the first one broken, or nil.
+// It holds the read lock for a time proportional to the size of the
+// cache.
+func (c *Cache) Verify() error {
+	c.rlock()
+	defer c.RUnlock()
+	return c.verify()
+}
+
+// verify is Verify with the cache locked.
+func (c *Cache) verify() error {
+	if len(c.cache) != c.lruIndex.Len() {
+		return fmt.Errorf("cache2go: map holds %d keys, list %d entries", len(c.cache), c.lruIndex.Len())
+	}
+	expiring := 0
+	for e := c.lruIndex.Front(); e != nil; e = e.Next() {
+		en := e.Value.(*entry)
+		if c.cache[en.key] != e {
+			return fmt.Errorf("cache2go: list entry %q is not in the map", en.key)
+		}
+		if en.index >= 0 {
+			expiring++
+		}
+	}
+	if expiring != len(c.ttlIndex) {
+		return fmt.Errorf("cache2go: %d entries expire, ttlIndex holds %d", expiring, len(c.ttlIndex))
+	}
+	for i, en := range c.ttlIndex {
+		if en.index != i {
+			return fmt.Errorf("cache2go: ttlIndex entry %q at %d has index %d", en.key, i, en.index)
+		}
+		if e, ok := c.cache[en.key]; !ok || e.Value.(*entry) != en {
+			return fmt.Errorf("cache2go: ttlIndex entry %q is not in the cache", en.key)
+		}
+		if c.sweep == SweepWheel {
+			if s := c.wheel.slots[en.wheelSlot]; en.wheelPos >= len(s) || s[en.wheelPos] != en {
+				return fmt.Errorf("cache2go: ttlIndex entry %q is not in its wheel slot", en.key)
+			}
+		} else if i > 0 && c.ttlIndex.Less(i, (i-1)/2) {
+			return fmt.Errorf("cache2go: ttlIndex entry %q expires before its parent", en.key)
+		}
+	}
+	if c.sweep == SweepWheel {
+		slotted := 0
+		for _, s := range c.wheel.slots {
+			slotted += len(s)
+		}
+		if slotted != len(c.ttlIndex) {
+			return fmt.Errorf("cache2go: wheel holds %d entries, ttlIndex %d", slotted, len(c.ttlIndex))
+		}
+	}
+	for class, l := range c.classes {
+		for e := l.Front(); e != nil; e = e.Next() {
+			en := e.Value.(*entry)
+			if en.class != class || en.classElem != e {
+				return fmt.Errorf("cache2go: class %q lists entry %q of class %q", class, en.key, en.class)
+			}
+			if e, ok := c.cache[en.key]; !ok || e.Value.(*entry) != en {
+				return fmt.Errorf("cache2go: class %q entry %q is not in the cache", class, en.key)
+			}
+		}
+	}
+	if c.policy == policyLRU {
+		return nil
+	}
+	h := &c.evictIndex
+	if h.Len() != c.lruIndex.Len() {
+		return fmt.Errorf("cache2go: evictIndex holds %d entries, list %d", h.Len(), c.lruIndex.Len())
+	}
+	for i, en := range h.entries {
+		if en.evictIndex != i {
+			return fmt.Errorf("cache2go: evictIndex entry %q at %d has index %d", en.key, i, en.evictIndex)
+		}
+		if e, ok := c.cache[en.key]; !ok || e.Value.(*entry) != en {
+			return fmt.Errorf("cache2go: evictIndex entry %q is not in the cache", en.key)
+		}
+		if i > 0 && h.Less(i, (i-1)/2) {
+			return fmt.Errorf("cache2go: evictIndex entry %q is evicted before its parent", en.key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package cache2go
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetDebug(t *testing.T) {
+	for _, cache := range []*Cache{New(10, time.Hour), NewLRUK(10, 2, 0), New(10, 0, WithCostAwareEviction())} {
+		cache.SetDebug(true)
+		for i := 0; i < 30; i++ {
+			key := fmt.Sprint(i % 13)
+			cache.SetWithTTL(key, i, time.Duration(i%4)*time.Minute)
+			cache.Get(fmt.Sprint(i % 7))
+			if i%5 == 0 {
+				cache.Delete(fmt.Sprint(i % 3))
+			}
+		}
+		cache.Rename("12", "twelve")
+		cache.Compact()
+		cache.Close()
+	}
+
+	cache := New(0, 0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.SetDebug(true)
+	defer func() {
+		err, _ := recover().(error)
+		if err == nil || !strings.Contains(err.Error(), "map") {
+			t.Error("broken invariant not reported: ", err)
+		}
+	}()
+	cache.Lock()
+	delete(cache.cache, "a")
+	cache.Unlock()
+	cache.Set("c", 3)
+}
+
+func TestVerify(t *testing.T) {
+	cache := New(0, time.Hour)
+	defer cache.Close()
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	if err := cache.Verify(); err != nil {
+		t.Error("consistent cache reported broken: ", err)
+	}
+	cache.Lock()
+	cache.cache["a"].Value.(*entry).index = 5
+	cache.Unlock()
+	if err := cache.Verify(); err == nil || !strings.Contains(err.Error(), "ttlIndex") {
+		t.Error("broken ttlIndex not reported: ", err)
+	}
+}
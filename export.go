@@ -0,0 +1,198 @@
+package cache2go
+
+import "time"
+
+// Entry is an exported copy of a cached entry.
+type Entry struct {
+	Key       string
+	Value     interface{}
+	Timestamp time.Time
+	// TTL is the entry's own expiration, zero if it uses the
+	// cache expiration.
+	TTL time.Duration
+	// Compressed tells that Value holds bytes compressed by
+	// SetBytes, which GetBytes decompresses once restored.
+	Compressed bool
+}
+
+// ToSlice returns the live entries ordered from the most to the
+// least recently used, without updating their recency.
+func (c *Cache) ToSlice() []Entry {
+	c.rlock()
+	defer c.RUnlock()
+	now := c.now()
+	entries := make([]Entry, 0, c.lruIndex.Len())
+	for e := c.lruIndex.Front(); e != nil; e = e.Next() {
+		en := e.Value.(*entry)
+		if !c.expired(en, now) {
+			entries = append(entries, Entry{Key: en.key, Value: en.value, Timestamp: en.timestamp, TTL: en.ttl, Compressed: en.compressed})
+		}
+	}
+	return entries
+}
+
+// Items returns the live values by key, without updating their
+// recency.
+func (c *Cache) Items() map[string]interface{} {
+	c.rlock()
+	defer c.RUnlock()
+	now := c.now()
+	items := make(map[string]interface{}, len(c.cache))
+	for k, e := range c.cache {
+		if en := e.Value.(*entry); !c.expired(en, now) {
+			items[k] = en.value
+		}
+	}
+	return items
+}
+
+// Partition splits the live entries of the cache in one pass into
+// those for which pred returns true and the rest, returning them as
+// two new caches with the same capacity and expiration but none of
+// the options or callbacks. The entries keep their timestamps, TTL
+// and recency order. The cache itself is not modified; Flush it
+// once the entries are migrated. pred runs with the cache locked
+// and must not call back into it; an entry for which it panics goes
+// to the rest.
+func (c *Cache) Partition(pred func(key string, value interface{}) bool) (matching, rest *Cache) {
+	c.rlock()
+	now := c.now()
+	var in, out []Entry
+	for e := c.lruIndex.Front(); e != nil; e = e.Next() {
+		en := e.Value.(*entry)
+		if c.expired(en, now) {
+			continue
+		}
+		saved := Entry{Key: en.key, Value: en.value, Timestamp: en.timestamp, TTL: en.ttl, Compressed: en.compressed}
+		match := false
+		c.safely(func() { match = pred(en.key, en.value) })
+		if match {
+			in = append(in, saved)
+		} else {
+			out = append(out, saved)
+		}
+	}
+	maxEntries, expire := c.maxEntries, c.expiration
+	c.RUnlock()
+	comp := c.compressor
+	return fromEntries(maxEntries, expire, in, now, comp), fromEntries(maxEntries, expire, out, now, comp)
+}
+
+// fromEntries returns a new cache holding entries, ordered from the
+// most to the least recently used, decompressing with comp the
+// values compressed by SetBytes.
+func fromEntries(maxEntries int, expire time.Duration, entries []Entry, now time.Time, comp Compressor) *Cache {
+	c := New(maxEntries, expire)
+	entries = c.admit(entries, comp)
+	c.lock()
+	defer c.unlock()
+	for i := len(entries) - 1; i >= 0; i-- {
+		c.restore(entries[i], now)
+	}
+	c.rebuildTTLIndex()
+	return c
+}
+
+// Snapshot is a point-in-time copy of the live entries of a cache.
+// It never changes after being taken, so it can be read from many
+// goroutines without locking. The values are shared with the cache,
+// not copied.
+type Snapshot struct {
+	entries []Entry
+	index   map[string]int
+}
+
+// Snapshot copies the live entries under a single lock, ordered from
+// the most to the least recently used, without updating their
+// recency.
+func (c *Cache) Snapshot() *Snapshot {
+	entries := c.ToSlice()
+	s := &Snapshot{entries: entries, index: make(map[string]int, len(entries))}
+	for i, en := range entries {
+		s.index[en.Key] = i
+	}
+	return s
+}
+
+// Get returns the value key had when the snapshot was taken.
+func (s *Snapshot) Get(key string) (value interface{}, ok bool) {
+	if i, ok := s.index[key]; ok {
+		return s.entries[i].Value, true
+	}
+	return nil, false
+}
+
+// Entry returns the entry stored under key.
+func (s *Snapshot) Entry(key string) (Entry, bool) {
+	if i, ok := s.index[key]; ok {
+		return s.entries[i], true
+	}
+	return Entry{}, false
+}
+
+// Len returns the number of entries in the snapshot.
+func (s *Snapshot) Len() int { return len(s.entries) }
+
+// Keys returns the keys from the most to the least recently used.
+func (s *Snapshot) Keys() []string {
+	keys := make([]string, len(s.entries))
+	for i, en := range s.entries {
+		keys[i] = en.Key
+	}
+	return keys
+}
+
+// Entries returns a copy of the entries from the most to the least
+// recently used.
+func (s *Snapshot) Entries() []Entry {
+	return append([]Entry(nil), s.entries...)
+}
+
+// Merge copies the live entries of other into the cache, keeping
+// their timestamps, TTL and recency order as Load does. For keys in
+// both caches the value becomes onConflict(current, theirs) and the
+// timestamp the newer of the two; a nil onConflict keeps the value
+// with the newer timestamp. onConflict receives byte values
+// compressed by SetBytes as they are stored, and what it returns is
+// stored uncompressed. Entries failing validation are skipped, and
+// for keys in both caches the current value is kept if the merged
+// one fails it. Entries are evicted as needed to respect the
+// capacity of the cache, with the usual callbacks. onConflict and
+// the validator of merged values run with the cache locked and must
+// not call back into it.
+func (c *Cache) Merge(other *Cache, onConflict func(a, b interface{}) interface{}) {
+	if other == c {
+		return
+	}
+	entries := c.admit(other.ToSlice(), other.compressor)
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	for i := len(entries) - 1; i >= 0; i-- {
+		theirs := entries[i]
+		e := c.live(theirs.Key, now)
+		if e == nil {
+			c.restore(theirs, now)
+			continue
+		}
+		en := e.Value.(*entry)
+		value, timestamp, compressed := en.value, en.timestamp, en.compressed
+		if theirs.Timestamp.After(timestamp) {
+			timestamp = theirs.Timestamp
+			if onConflict == nil {
+				value, compressed = theirs.Value, theirs.Compressed
+			}
+		}
+		if onConflict != nil {
+			current := en.value
+			c.safely(func() { value = onConflict(current, theirs.Value) })
+			compressed = false
+			if c.validateValue(theirs.Key, value) != nil {
+				continue
+			}
+		}
+		c.set(theirs.Key, value, en.cost, en.ttl)
+		en.timestamp, en.compressed = timestamp, compressed
+	}
+	c.rebuildTTLIndex()
+}
@@ -0,0 +1,138 @@
+package cache2go
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestToSlice(t *testing.T) {
+	cache := New(0, time.Hour)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Get("a")
+	cache.Lock()
+	cache.cache["c"].Value.(*entry).expires = time.Now()
+	cache.Unlock()
+	entries := cache.ToSlice()
+	if len(entries) != 2 || entries[0].Key != "a" || entries[1].Key != "b" {
+		t.Error("Error exporting entries in recency order: ", entries)
+	}
+	if entries[1].Value != 2 || entries[1].Timestamp.IsZero() {
+		t.Error("Error exporting entry: ", entries[1])
+	}
+	if items := cache.Items(); len(items) != 2 || items["a"] != 1 {
+		t.Error("Error exporting items: ", items)
+	}
+}
+
+func TestPartition(t *testing.T) {
+	cache := New(10, time.Hour)
+	for i := 0; i < 6; i++ {
+		cache.Set(fmt.Sprint(i), i)
+	}
+	cache.Get("0")
+	even, odd := cache.Partition(func(key string, value interface{}) bool {
+		return value.(int)%2 == 0
+	})
+	if keys := even.Keys(); len(keys) != 3 || keys[0] != "0" || keys[1] != "4" || keys[2] != "2" {
+		t.Error("Error partitioning matching entries in recency order: ", keys)
+	}
+	if keys := odd.Keys(); len(keys) != 3 || keys[0] != "5" {
+		t.Error("Error partitioning the rest: ", keys)
+	}
+	a, _ := cache.EntryInfo("2")
+	b, _ := even.EntryInfo("2")
+	if !a.Timestamp.Equal(b.Timestamp) {
+		t.Error("Error preserving timestamps: ", a.Timestamp, b.Timestamp)
+	}
+	if cache.Len() != 6 {
+		t.Error("Partition modified the cache: ", cache.Len())
+	}
+}
+
+func TestPartitionPanicking(t *testing.T) {
+	cache := New(0, 0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	in, rest := cache.Partition(func(key string, value interface{}) bool {
+		if key == "a" {
+			panic("boom")
+		}
+		return true
+	})
+	if in.Len() != 1 || rest.Len() != 1 {
+		t.Error("a panicking predicate should send the entry to the rest: ", in.Len(), rest.Len())
+	}
+	cache.Set("c", 3)
+	if cache.Len() != 3 {
+		t.Error("a panicking predicate should not leave the cache locked")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	cache := New(0, 0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	snap := cache.Snapshot()
+	cache.Set("a", 10)
+	cache.Delete("b")
+	if v, ok := snap.Get("a"); !ok || v != 1 {
+		t.Error("snapshot changed with the cache: ", v)
+	}
+	if _, ok := snap.Get("c"); ok {
+		t.Error("snapshot reports missing keys")
+	}
+	if en, ok := snap.Entry("b"); !ok || en.Value != 2 || en.Timestamp.IsZero() {
+		t.Error("Error reading snapshot entry: ", en)
+	}
+	if snap.Len() != 2 || fmt.Sprint(snap.Keys()) != "[b a]" || len(snap.Entries()) != 2 {
+		t.Error("Error listing snapshot: ", snap.Keys())
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			snap.Get("a")
+			snap.Keys()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMerge(t *testing.T) {
+	a := New(3, 0)
+	b := New(0, 0)
+	b.Set("x", 10)
+	a.Set("x", 1)
+	a.Set("y", 2)
+	b.Set("z", 30)
+	b.Set("w", 40)
+	var evicted []string
+	a.OnEvicted(func(key string, value interface{}) {
+		evicted = append(evicted, key)
+	})
+	a.Merge(b, func(mine, theirs interface{}) interface{} {
+		return mine.(int) + theirs.(int)
+	})
+	if v, _ := a.Peek("x"); v != 11 {
+		t.Error("Error resolving conflict: ", v)
+	}
+	if fmt.Sprint(a.Keys()) != "[w z x]" || fmt.Sprint(evicted) != "[y]" {
+		t.Error("Error merging within capacity: ", a.Keys(), evicted)
+	}
+	ax, _ := a.EntryInfo("x")
+	bx, _ := b.EntryInfo("x")
+	if ax.Timestamp.Before(bx.Timestamp) {
+		t.Error("Error keeping the newer timestamp")
+	}
+	c := New(0, 0)
+	c.Set("x", 100)
+	a.Merge(c, nil)
+	if v, _ := a.Peek("x"); v != 100 {
+		t.Error("Error keeping the newer value without onConflict: ", v)
+	}
+}
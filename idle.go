@@ -0,0 +1,13 @@
+package cache2go
+
+import "time"
+
+// NewWithIdleAndLifetime creates a cache whose entries expire either
+// idle after they were last set or retrieved with Get, or lifetime
+// after they were set, whichever comes first, like sessions ending
+// after inactivity or at a hard limit. A zero idle or lifetime
+// disables that timeout.
+func NewWithIdleAndLifetime(maxEntries int, idle, lifetime time.Duration, opts ...Option) *Cache {
+	withIdle := func(c *Cache) { c.idle = idle }
+	return New(maxEntries, lifetime, append([]Option{withIdle}, opts...)...)
+}
@@ -0,0 +1,33 @@
+package cache2go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdleAndLifetime(t *testing.T) {
+	cache := NewWithIdleAndLifetime(0, 50*time.Millisecond, 120*time.Millisecond)
+	defer cache.Close()
+	cache.Set("active", 1)
+	cache.Set("idle", 2)
+	for i := 0; i < 4; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if _, ok := cache.Get("active"); !ok {
+			t.Fatal("entry read within the idle timeout expired")
+		}
+	}
+	if cache.Contains("idle") {
+		t.Error("idle entry did not expire")
+	}
+	time.Sleep(80 * time.Millisecond)
+	if cache.Len() != 0 {
+		t.Error("entry outlived its lifetime: ", cache.Keys())
+	}
+	cache = NewWithIdleAndLifetime(0, 20*time.Millisecond, 0)
+	defer cache.Close()
+	cache.Set("a", 1)
+	time.Sleep(50 * time.Millisecond)
+	if cache.Len() != 0 {
+		t.Error("idle entry without lifetime not swept: ", cache.Keys())
+	}
+}
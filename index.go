@@ -0,0 +1,61 @@
+package cache2go
+
+// WithIndex maintains a secondary index of the entries by the result
+// of fn on their value, queried with GetByIndex. fn runs with the
+// cache locked whenever a value is stored and must not call back
+// into it; values for which it returns "" are not indexed, nor are
+// those for which it panics.
+func WithIndex(fn func(value interface{}) string) Option {
+	return func(c *Cache) {
+		c.indexFn = fn
+		c.index = make(map[string]map[string]struct{})
+	}
+}
+
+// GetByIndex returns the live values whose index value, as computed
+// by the function given to WithIndex, is indexValue, without
+// updating their recency. The order of the values is unspecified.
+func (c *Cache) GetByIndex(indexValue string) []interface{} {
+	c.rlock()
+	defer c.RUnlock()
+	now := c.now()
+	var values []interface{}
+	for key := range c.index[indexValue] {
+		e, ok := c.cache[key]
+		if !ok {
+			continue
+		}
+		if en := e.Value.(*entry); en.indexed == indexValue && !c.expired(en, now) {
+			values = append(values, en.value)
+		}
+	}
+	return values
+}
+
+// reindex updates the index after the value of en changed.
+func (c *Cache) reindex(en *entry) {
+	c.unindex(en)
+	c.safely(func() { en.indexed = c.indexFn(en.value) })
+	if en.indexed == "" {
+		return
+	}
+	keys := c.index[en.indexed]
+	if keys == nil {
+		keys = make(map[string]struct{})
+		c.index[en.indexed] = keys
+	}
+	keys[en.key] = struct{}{}
+}
+
+// unindex removes en from the index.
+func (c *Cache) unindex(en *entry) {
+	if en.indexed == "" {
+		return
+	}
+	keys := c.index[en.indexed]
+	delete(keys, en.key)
+	if len(keys) == 0 {
+		delete(c.index, en.indexed)
+	}
+	en.indexed = ""
+}
@@ -0,0 +1,72 @@
+package cache2go
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+type user struct {
+	name, team string
+}
+
+func TestIndex(t *testing.T) {
+	cache := New(3, 0, WithIndex(func(value interface{}) string {
+		return value.(user).team
+	}))
+	team := func(name string) string {
+		var names []string
+		for _, v := range cache.GetByIndex(name) {
+			names = append(names, v.(user).name)
+		}
+		sort.Strings(names)
+		return fmt.Sprint(names)
+	}
+	cache.Set("1", user{"ann", "red"})
+	cache.Set("2", user{"bob", "red"})
+	cache.Set("3", user{"cid", "blue"})
+	if team("red") != "[ann bob]" || team("blue") != "[cid]" || team("green") != "[]" {
+		t.Error("Error indexing values: ", team("red"), team("blue"))
+	}
+	cache.Set("2", user{"bob", "blue"})
+	cache.Delete("3")
+	if team("red") != "[ann]" || team("blue") != "[bob]" {
+		t.Error("Error updating the index: ", team("red"), team("blue"))
+	}
+	cache.Rename("2", "b")
+	cache.Set("4", user{"dan", "red"})
+	cache.Set("5", user{"eve", "red"})
+	if team("red") != "[dan eve]" || team("blue") != "[bob]" {
+		t.Error("Error indexing renamed and evicted entries: ", team("red"), team("blue"))
+	}
+	cache.Flush()
+	if team("red") != "[]" {
+		t.Error("Error flushing the index: ", team("red"))
+	}
+}
+
+func TestIndexWithValue(t *testing.T) {
+	cache := New(0, 0, WithIndex(func(value interface{}) string {
+		return value.(user).team
+	}))
+	cache.Set("a", user{"ann", "red"})
+	cache.WithValue("a", func(v interface{}) interface{} {
+		cache.Delete("a")
+		return user{"ann", "blue"}
+	})
+	if values := cache.GetByIndex("blue"); len(values) != 0 {
+		t.Error("deleted entry indexed: ", values)
+	}
+	cache.Set("b", user{"bob", "red"})
+	cache.WithValue("b", func(v interface{}) interface{} {
+		cache.Delete("b")
+		cache.Set("b", user{"bea", "red"})
+		return user{"bob", "blue"}
+	})
+	if values := cache.GetByIndex("red"); len(values) != 1 || values[0].(user).name != "bea" {
+		t.Error("Error indexing the entry replacing the one in use: ", values)
+	}
+	if values := cache.GetByIndex("blue"); len(values) != 0 {
+		t.Error("replaced entry indexed: ", values)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !slicelist
+// +build !slicelist
+
+package cache2go
+
+import "container/list"
+
+// lruList is the recency list of the cache. It is container/list
+// unless the package is built with the slicelist tag, see
+// list_slice.go.
+type lruList = list.List
+
+type lruElement = list.Element
+
+func newLRUList() *lruList { return list.New() }
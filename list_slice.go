@@ -0,0 +1,128 @@
+//go:build slicelist
+// +build slicelist
+
+package cache2go
+
+import "math/bits"
+
+// lruChunk is how many elements the first chunk of an lruList holds;
+// each chunk after it is twice as large as the previous one.
+const lruChunk = 16
+
+// lruList is a doubly linked list with the subset of the
+// container/list API used by the cache, storing its elements in
+// chunks, linked by index. Elements are allocated a chunk at a time
+// and reused once removed, so an entry costs one allocation instead
+// of two and the links stay close in memory. Removed elements must
+// not be used once another element is pushed.
+type lruList struct {
+	chunks     [][]lruElement
+	used       int32 // slots handed out so far
+	free       int32 // first removed slot, linked by next
+	head, tail int32
+	len        int
+}
+
+type lruElement struct {
+	Value interface{}
+	// list is nil once the element is removed.
+	list             *lruList
+	self, prev, next int32
+}
+
+func newLRUList() *lruList {
+	return &lruList{free: -1, head: -1, tail: -1}
+}
+
+func (l *lruList) at(i int32) *lruElement {
+	if i < 0 {
+		return nil
+	}
+	// chunk k starts at index lruChunk*(2^k-1)
+	k := bits.Len32(uint32(i/lruChunk+1)) - 1
+	return &l.chunks[k][i-lruChunk*(1<<uint(k)-1)]
+}
+
+// Next returns the next list element or nil.
+func (e *lruElement) Next() *lruElement {
+	if e.list == nil {
+		return nil
+	}
+	return e.list.at(e.next)
+}
+
+// Prev returns the previous list element or nil.
+func (e *lruElement) Prev() *lruElement {
+	if e.list == nil {
+		return nil
+	}
+	return e.list.at(e.prev)
+}
+
+func (l *lruList) Len() int { return l.len }
+
+func (l *lruList) Front() *lruElement { return l.at(l.head) }
+
+func (l *lruList) Back() *lruElement { return l.at(l.tail) }
+
+// PushFront inserts a new element with value v at the front of the
+// list and returns it.
+func (l *lruList) PushFront(v interface{}) *lruElement {
+	i := l.free
+	if i >= 0 {
+		l.free = l.at(i).next
+	} else {
+		if k := len(l.chunks); l.used == lruChunk*(1<<uint(k)-1) {
+			l.chunks = append(l.chunks, make([]lruElement, lruChunk<<uint(k)))
+		}
+		i = l.used
+		l.used++
+	}
+	e := l.at(i)
+	*e = lruElement{Value: v, list: l, self: i, prev: -1, next: l.head}
+	if l.head >= 0 {
+		l.at(l.head).prev = i
+	} else {
+		l.tail = i
+	}
+	l.head = i
+	l.len++
+	return e
+}
+
+// MoveToFront moves e to the front of the list.
+func (l *lruList) MoveToFront(e *lruElement) {
+	if e.list != l || l.head == e.self {
+		return
+	}
+	l.unlink(e)
+	e.prev, e.next = -1, l.head
+	l.at(l.head).prev = e.self
+	l.head = e.self
+}
+
+// Remove removes e from the list and returns its value.
+func (l *lruList) Remove(e *lruElement) interface{} {
+	v := e.Value
+	if e.list == l {
+		l.unlink(e)
+		l.len--
+		// drop the value so the slot does not keep it alive
+		*e = lruElement{self: e.self, next: l.free}
+		l.free = e.self
+	}
+	return v
+}
+
+func (l *lruList) unlink(e *lruElement) {
+	if e.prev >= 0 {
+		l.at(e.prev).next = e.next
+	} else {
+		l.head = e.next
+	}
+	if e.next >= 0 {
+		l.at(e.next).prev = e.prev
+	} else {
+		l.tail = e.prev
+	}
+}
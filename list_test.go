@@ -0,0 +1,63 @@
+package cache2go
+
+import (
+	"strconv"
+	"testing"
+)
+
+// The list benchmarks measure the recency list backing the cache;
+// compare container/list with the slice backed list by running them
+// with and without -tags slicelist.
+
+func TestLRUList(t *testing.T) {
+	l := newLRUList()
+	var elems []*lruElement
+	for i := 0; i < 100; i++ {
+		elems = append(elems, l.PushFront(i))
+	}
+	for i := 0; i < 100; i += 2 {
+		l.Remove(elems[i])
+	}
+	l.MoveToFront(elems[1])
+	for i := 0; i < 10; i++ {
+		l.PushFront(100 + i)
+	}
+	var got []int
+	for e := l.Back(); e != nil; e = e.Prev() {
+		got = append(got, e.Value.(int))
+	}
+	if len(got) != l.Len() || l.Len() != 60 {
+		t.Fatal("Error counting list elements: ", len(got), l.Len())
+	}
+	if got[0] != 3 || got[49] != 1 || got[59] != 109 {
+		t.Error("Error ordering list elements: ", got)
+	}
+}
+
+func BenchmarkSetEvict(b *testing.B) {
+	cache := New(10000, 0)
+	keys := make([]string, b.N)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Set(keys[i], i)
+	}
+}
+
+func BenchmarkGetHit(b *testing.B) {
+	const n = 100000
+	cache := New(n, 0)
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+		cache.Set(keys[i], i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Get(keys[i%n])
+	}
+}
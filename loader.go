@@ -0,0 +1,214 @@
+package cache2go
+
+import (
+	"errors"
+	"time"
+)
+
+// errLoaderPanicked is returned by GetOrLoad when the loader panics.
+var errLoaderPanicked = errors.New("cache2go: loader panicked")
+
+// SetWithTTL adds a value to the cache that expires ttl after being
+// set, overriding the cache expiration. A zero ttl uses the cache
+// expiration.
+func (c *Cache) SetWithTTL(key string, value interface{}, ttl time.Duration) {
+	if c.validateValue(key, value) != nil {
+		return
+	}
+	c.lock()
+	c.set(key, value, 1, ttl)
+	c.unlock()
+}
+
+// SetOrExtend is like SetWithTTL, but when key is already cached it
+// never brings its expiration closer: the entry expires at the
+// latest of its current expiration and ttl from now.
+func (c *Cache) SetOrExtend(key string, value interface{}, ttl time.Duration) {
+	if c.validateValue(key, value) != nil {
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	if e := c.live(key, now); e != nil {
+		en := e.Value.(*entry)
+		lifetime := ttl
+		if lifetime == 0 {
+			lifetime = c.expiration
+		}
+		switch remaining := en.expires.Sub(now); {
+		case en.index < 0:
+			// never expires; the cache expiration is zero
+			ttl = 0
+		case lifetime > 0 && remaining > lifetime:
+			ttl = remaining
+		}
+	}
+	c.set(key, value, 1, ttl)
+}
+
+// GetOrLoad returns the value stored under key, calling loader to
+// produce it on a miss. The loader decides whether its result is
+// stored: when cacheable is true and err is nil the value, which may
+// be nil to remember that something does not exist, is stored with
+// SetWithTTL semantics for ttl. Errors and results that are not
+// cacheable are returned without being stored.
+func (c *Cache) GetOrLoad(key string, loader func() (value interface{}, cacheable bool, ttl time.Duration, err error)) (interface{}, error) {
+	if value, ok := c.Get(key); ok {
+		return value, nil
+	}
+	var (
+		value     interface{}
+		cacheable bool
+		ttl       time.Duration
+	)
+	err := errLoaderPanicked
+	c.safely(func() { value, cacheable, ttl, err = loader() })
+	if err != nil {
+		return nil, err
+	}
+	if cacheable {
+		c.SetWithTTL(key, value, ttl)
+	}
+	return value, nil
+}
+
+// ItemWithTTL is a value loaded by GetOrLoadMany along with the TTL
+// it is stored with, zero meaning the cache expiration.
+type ItemWithTTL struct {
+	Value interface{}
+	TTL   time.Duration
+}
+
+// batchLoad is shared by the GetOrLoadMany calls waiting for the
+// same key to be loaded.
+type batchLoad struct {
+	// done is closed once the load finished; ok tells whether
+	// the loader returned the key.
+	done chan struct{}
+	item ItemWithTTL
+	ok   bool
+}
+
+// GetOrLoadMany returns the values stored under keys, calling loader
+// once with the keys missing from the cache and storing each item it
+// returns with its own TTL. Keys that calls running concurrently are
+// already loading are not passed to loader; the call waits for them
+// instead. Keys loader does not return, or all of them if it panics,
+// are missing from the result.
+func (c *Cache) GetOrLoadMany(keys []string, loader func(missing []string) map[string]ItemWithTTL) map[string]interface{} {
+	values := make(map[string]interface{}, len(keys))
+	var missing []string
+	waiting := make(map[string]*batchLoad)
+	c.lock()
+	for _, key := range keys {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if _, ok := waiting[key]; ok {
+			continue
+		}
+		if value, ok := c.get(key); ok {
+			values[key] = value
+			continue
+		}
+		if l, ok := c.loading[key]; ok {
+			waiting[key] = l
+			continue
+		}
+		if c.loading == nil {
+			c.loading = make(map[string]*batchLoad)
+		}
+		c.loading[key] = &batchLoad{done: make(chan struct{})}
+		missing = append(missing, key)
+	}
+	c.unlock()
+
+	if len(missing) > 0 {
+		var loaded map[string]ItemWithTTL
+		c.safely(func() { loaded = loader(missing) })
+		valid := make(map[string]bool, len(loaded))
+		for key, item := range loaded {
+			valid[key] = c.validateValue(key, item.Value) == nil
+		}
+		c.lock()
+		for _, key := range missing {
+			l := c.loading[key]
+			delete(c.loading, key)
+			if item, ok := loaded[key]; ok {
+				l.item, l.ok = item, true
+				values[key] = item.Value
+				if valid[key] {
+					c.set(key, item.Value, 1, item.TTL)
+				}
+			}
+			close(l.done)
+		}
+		c.unlock()
+	}
+	// keys are loaded before waiting, so calls cannot wait on each
+	// other in a cycle
+	for key, l := range waiting {
+		<-l.done
+		if l.ok {
+			values[key] = l.item.Value
+		}
+	}
+	return values
+}
+
+// SetWithTimestamp adds a value to the cache as if it had been set at
+// originTime, such as the last modification time reported by the
+// origin, so that it expires relative to that time. A value already
+// past its expiration is not stored, and any value stored under key
+// is removed. An originTime in the future is taken as now.
+func (c *Cache) SetWithTimestamp(key string, value interface{}, originTime time.Time) {
+	if c.validateValue(key, value) != nil {
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	if originTime.After(now) {
+		originTime = now
+	}
+	if c.expiration > 0 && !now.Before(originTime.Add(c.expiration)) {
+		if e, ok := c.cache[key]; ok {
+			c.removeElement(e)
+		}
+		return
+	}
+	if _, _, stored := c.set(key, value, 1, 0); stored {
+		if e, ok := c.cache[key]; ok {
+			en := e.Value.(*entry)
+			en.timestamp = originTime
+			c.schedule(en)
+		}
+	}
+}
+
+// Touch restarts the lifetime and the idle timeout of the entry under
+// key as if it had just been set, without changing its value or
+// recency, and reports
+// whether the key was found.
+func (c *Cache) Touch(key string) bool {
+	return c.TouchMany([]string{key}) == 1
+}
+
+// TouchMany is like Touch for several keys under a single lock, so
+// their expirations stay aligned. It returns how many were found.
+func (c *Cache) TouchMany(keys []string) int {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	n := 0
+	for _, key := range keys {
+		if e := c.live(key, now); e != nil {
+			en := e.Value.(*entry)
+			en.timestamp, en.lastAccess = now, now
+			c.schedule(en)
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,184 @@
+package cache2go
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetWithTTL(t *testing.T) {
+	cache := New(0, time.Hour)
+	cache.SetWithTTL("short", 1, time.Millisecond)
+	cache.Set("long", 2)
+	time.Sleep(20 * time.Millisecond)
+	if cache.Contains("short") || !cache.Contains("long") {
+		t.Error("Error expiring entry with its own TTL")
+	}
+	// the cleanup also runs for caches without an expiration
+	cache = New(0, 0)
+	cache.SetWithTTL("short", 1, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if cache.Len() != 0 {
+		t.Error("Error cleaning entry with its own TTL: ", cache.Len())
+	}
+}
+
+func TestSetOrExtend(t *testing.T) {
+	cache := New(0, 0)
+	defer cache.Close()
+	expires := func(key string) time.Duration {
+		info, _ := cache.EntryInfo(key)
+		return time.Until(info.Expires)
+	}
+	cache.SetOrExtend("a", 1, time.Hour)
+	cache.SetOrExtend("a", 2, time.Minute)
+	if v, _ := cache.Get("a"); v != 2 || expires("a") < 59*time.Minute {
+		t.Error("Error keeping the longer expiration: ", v, expires("a"))
+	}
+	cache.SetOrExtend("a", 3, 2*time.Hour)
+	if expires("a") < 119*time.Minute {
+		t.Error("Error extending the expiration: ", expires("a"))
+	}
+	cache.Set("forever", 1)
+	cache.SetOrExtend("forever", 2, time.Minute)
+	if info, _ := cache.EntryInfo("forever"); !info.Expires.IsZero() {
+		t.Error("SetOrExtend made an entry expire: ", info.Expires)
+	}
+}
+
+func TestGetOrLoad(t *testing.T) {
+	cache := New(0, 0)
+	calls := 0
+	errDown := errors.New("backend down")
+	load := func(value interface{}, cacheable bool, err error) func() (interface{}, bool, time.Duration, error) {
+		return func() (interface{}, bool, time.Duration, error) {
+			calls++
+			return value, cacheable, time.Hour, err
+		}
+	}
+	if _, err := cache.GetOrLoad("a", load(nil, true, errDown)); err != errDown || cache.Contains("a") {
+		t.Error("errors should not be cached: ", err)
+	}
+	if v, _ := cache.GetOrLoad("a", load(1, false, nil)); v != 1 || cache.Contains("a") {
+		t.Error("results that are not cacheable should not be stored")
+	}
+	// a cacheable nil remembers that "a" does not exist
+	cache.GetOrLoad("a", load(nil, true, nil))
+	if v, err := cache.GetOrLoad("a", load(2, true, nil)); v != nil || err != nil || calls != 3 {
+		t.Error("Error caching negative result: ", v, calls)
+	}
+	if info, _ := cache.EntryInfo("a"); info.Expires.IsZero() {
+		t.Error("Error storing result with its TTL")
+	}
+	_, err := cache.GetOrLoad("b", func() (interface{}, bool, time.Duration, error) { panic("boom") })
+	if err != errLoaderPanicked {
+		t.Error("Error recovering from panicking loader: ", err)
+	}
+}
+
+func TestGetOrLoadMany(t *testing.T) {
+	cache := New(0, 0)
+	defer cache.Close()
+	cache.Set("a", 1)
+	release := make(chan struct{})
+	var calls [][]string
+	var mu sync.Mutex
+	loader := func(missing []string) map[string]ItemWithTTL {
+		mu.Lock()
+		calls = append(calls, missing)
+		mu.Unlock()
+		<-release
+		items := make(map[string]ItemWithTTL)
+		for _, key := range missing {
+			if key != "none" {
+				items[key] = ItemWithTTL{Value: key + "!", TTL: time.Hour}
+			}
+		}
+		items["short"] = ItemWithTTL{Value: "short!", TTL: time.Millisecond}
+		return items
+	}
+	results := make(chan map[string]interface{}, 2)
+	go func() { results <- cache.GetOrLoadMany([]string{"a", "b", "none", "short"}, loader) }()
+	for {
+		mu.Lock()
+		n := len(calls)
+		mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	go func() { results <- cache.GetOrLoadMany([]string{"b", "c"}, loader) }()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	r1, r2 := <-results, <-results
+	if len(r1) < len(r2) {
+		r1, r2 = r2, r1
+	}
+	if len(r1) != 3 || r1["a"] != 1 || r1["b"] != "b!" || r1["short"] != "short!" {
+		t.Error("Error loading missing keys: ", r1)
+	}
+	if len(r2) != 2 || r2["b"] != "b!" || r2["c"] != "c!" {
+		t.Error("Error coalescing loads: ", r2)
+	}
+	if len(calls) != 2 || len(calls[1]) != 1 || calls[1][0] != "c" {
+		t.Error("keys loading were loaded again: ", calls)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !cache.Contains("b") || cache.Contains("short") || cache.Contains("none") {
+		t.Error("Error storing loaded items with their TTL: ", cache.Keys())
+	}
+}
+
+func TestSetWithTimestamp(t *testing.T) {
+	cache := New(0, time.Hour)
+	defer cache.Close()
+	cache.SetWithTimestamp("a", 1, time.Now().Add(-59*time.Minute))
+	if d, ok := cache.GetTTL("a"); !ok || d > time.Minute {
+		t.Error("Error expiring relative to the origin time: ", d, ok)
+	}
+	cache.Set("old", 1)
+	cache.SetWithTimestamp("old", 2, time.Now().Add(-2*time.Hour))
+	if cache.Contains("old") {
+		t.Error("value older than the expiration stored")
+	}
+	cache.SetWithTimestamp("future", 3, time.Now().Add(time.Hour))
+	if d, _ := cache.GetTTL("future"); d > time.Hour {
+		t.Error("future origin time extended the lifetime: ", d)
+	}
+}
+
+func TestTouchMany(t *testing.T) {
+	cache := New(0, time.Hour)
+	defer cache.Close()
+	cache.SetWithTimestamp("a", 1, time.Now().Add(-30*time.Minute))
+	cache.SetWithTimestamp("b", 2, time.Now().Add(-40*time.Minute))
+	cache.Set("c", 3)
+	if n := cache.TouchMany([]string{"a", "b", "missing"}); n != 2 {
+		t.Error("Error counting touched keys: ", n)
+	}
+	for _, key := range []string{"a", "b"} {
+		if d, _ := cache.GetTTL(key); d < 59*time.Minute {
+			t.Error("Error restarting lifetime of ", key, ": ", d)
+		}
+	}
+	if keys := cache.Keys(); keys[0] != "c" {
+		t.Error("TouchMany changed the recency: ", keys)
+	}
+	if !cache.Touch("c") || cache.Touch("missing") {
+		t.Error("Error reporting touched key")
+	}
+
+	now := time.Now()
+	cache = NewWithIdleAndLifetime(0, time.Hour, 0)
+	defer cache.Close()
+	cache.clock = func() time.Time { return now }
+	cache.Set("a", 1)
+	now = now.Add(40 * time.Minute)
+	cache.Touch("a")
+	now = now.Add(40 * time.Minute)
+	if !cache.Contains("a") {
+		t.Error("Touch should restart the idle timeout")
+	}
+}
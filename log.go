@@ -0,0 +1,20 @@
+package cache2go
+
+// WithLogger calls fn on the significant events of the cache: a
+// "debug" message for each entry evicted for capacity or removed
+// after expiring, and an "info" message when the cache is flushed.
+// kv holds alternating keys and values describing the event, such as
+// "key" and the key of the entry. fn runs with the cache locked and
+// must not call back into it. Without a logger nothing is formatted
+// or allocated.
+func WithLogger(fn func(level, msg string, kv ...interface{})) Option {
+	return func(c *Cache) {
+		c.logger = fn
+	}
+}
+
+// log calls the logger, which must be set; callers check it first so
+// the arguments are not built for nothing.
+func (c *Cache) log(level, msg string, kv ...interface{}) {
+	c.safely(func() { c.logger(level, msg, kv...) })
+}
@@ -0,0 +1,25 @@
+package cache2go
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	var logged []string
+	cache := New(1, 0, WithLogger(func(level, msg string, kv ...interface{}) {
+		logged = append(logged, fmt.Sprint(level, " ", msg, " ", kv[:2]))
+	}))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.SetWithTTL("b", 2, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	cache.Get("b")
+	cache.Set("c", 3)
+	cache.Flush()
+	want := "[debug evicted [key a] debug expired [key b] info flushed [len 1]]"
+	if fmt.Sprint(logged) != want {
+		t.Error("Error logging events: ", logged)
+	}
+}
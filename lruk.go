@@ -0,0 +1,28 @@
+package cache2go
+
+import "time"
+
+// NewLRUK creates a cache evicting with the LRU-K policy: the victim
+// is the entry whose k-th most recent access, counting the one that
+// added it, is the oldest. Entries accessed fewer than k times are
+// evicted first, least recently used first, which keeps one-off
+// scans from flushing the entries in regular use.
+func NewLRUK(maxEntries int, k int, expire time.Duration, opts ...Option) *Cache {
+	if k < 1 {
+		k = 1
+	}
+	return New(maxEntries, expire, append([]Option{func(c *Cache) {
+		c.policy = policyLRUK
+		c.lrukK = k
+		c.evictIndex.less = func(a, b *entry) bool {
+			aFull, bFull := len(a.history) == k, len(b.history) == k
+			if aFull != bFull {
+				return bFull
+			}
+			if !aFull {
+				return a.lastAccess.Before(b.lastAccess)
+			}
+			return a.history[0].Before(b.history[0])
+		}
+	}}, opts...)...)
+}
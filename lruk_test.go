@@ -0,0 +1,35 @@
+package cache2go
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestLRUK(t *testing.T) {
+	cache := NewLRUK(4, 2, 0)
+	cache.Set("hot1", 1)
+	cache.Set("hot2", 2)
+	time.Sleep(time.Millisecond)
+	cache.Get("hot1")
+	cache.Get("hot2")
+	// a scan touching each key once does not evict the hot ones
+	for i := 0; i < 10; i++ {
+		time.Sleep(time.Microsecond)
+		cache.Set(fmt.Sprintf("scan%d", i), i)
+	}
+	if !cache.Contains("hot1") || !cache.Contains("hot2") || cache.Len() != 4 {
+		t.Error("Error resisting scans: ", cache.Keys())
+	}
+	if !cache.Contains("scan9") || cache.Contains("scan7") {
+		t.Error("entries seen once should be evicted least recently used first: ", cache.Keys())
+	}
+	// once the scan keys are used again they compete on their
+	// second most recent access
+	cache.Get("scan9")
+	cache.Get("scan8")
+	cache.Set("new", 0)
+	if !cache.Contains("scan8") || cache.Contains("hot1") {
+		t.Error("Error evicting by K-th access: ", cache.Keys())
+	}
+}
@@ -0,0 +1,72 @@
+package cache2go
+
+import (
+	"runtime"
+	"time"
+)
+
+// heapCheckInterval is how often a cache created with NewMemoryAware
+// reads the heap usage.
+const heapCheckInterval = time.Second
+
+// NewMemoryAware creates a cache without an entry limit that watches
+// the heap of the process instead: whenever the heap in use, as
+// reported by runtime.ReadMemStats, is above targetHeapBytes, it
+// evicts its least recently used entries in proportion to the
+// excess. It is an approximate guard for values whose size is not
+// known; the heap also holds what the rest of the process allocated,
+// and it only shrinks once the garbage collector runs. The heap is
+// checked every second until Close.
+func NewMemoryAware(targetHeapBytes uint64, expire time.Duration, opts ...Option) *Cache {
+	withTarget := func(c *Cache) {
+		c.heapTarget = targetHeapBytes
+		c.heapInUse = heapInUse
+	}
+	return New(0, expire, append([]Option{withTarget}, opts...)...)
+}
+
+// heapInUse returns the bytes allocated on the heap.
+func heapInUse() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// watchHeap checks the heap usage until done is closed.
+func (c *Cache) watchHeap(done chan struct{}) {
+	ticker := time.NewTicker(heapCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.shrinkToHeap()
+		}
+	}
+}
+
+// shrinkToHeap evicts the share of the entries that the heap in use
+// exceeds the target by, and at least one entry when it does.
+func (c *Cache) shrinkToHeap() {
+	inUse := c.heapInUse()
+	if inUse <= c.heapTarget {
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	n := int(float64(c.lruIndex.Len()) * float64(inUse-c.heapTarget) / float64(inUse))
+	if n == 0 {
+		n = 1
+	}
+	for ; n > 0 && c.lruIndex.Len() > 0; n-- {
+		size := c.lruIndex.Len()
+		c.removeOldest(nil)
+		if c.lruIndex.Len() == size {
+			break
+		}
+	}
+	if c.logger != nil {
+		c.log("info", "heap over target", "heap", inUse, "target", c.heapTarget, "len", c.lruIndex.Len())
+	}
+}
@@ -0,0 +1,32 @@
+package cache2go
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNewMemoryAware(t *testing.T) {
+	inUse := uint64(900)
+	cache := NewMemoryAware(1000, time.Hour, func(c *Cache) {
+		c.heapInUse = func() uint64 { return inUse }
+	})
+	defer cache.Close()
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprint(i), i)
+	}
+	cache.shrinkToHeap()
+	if cache.Len() != 10 {
+		t.Error("evicted under the target: ", cache.Len())
+	}
+	inUse = 2000
+	cache.shrinkToHeap()
+	if cache.Len() != 5 || cache.Contains("4") || !cache.Contains("5") {
+		t.Error("Error evicting in proportion to the excess: ", cache.Keys())
+	}
+	inUse = 1001
+	cache.shrinkToHeap()
+	if cache.Len() != 4 {
+		t.Error("Error evicting at least one entry: ", cache.Len())
+	}
+}
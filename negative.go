@@ -0,0 +1,47 @@
+package cache2go
+
+import "time"
+
+// LookupResult tells apart the outcomes of Lookup.
+type LookupResult int
+
+const (
+	// Miss means nothing is known about the key.
+	Miss LookupResult = iota
+	// Hit means the key has a cached value.
+	Hit
+	// KnownMiss means the key was marked absent with SetMiss.
+	KnownMiss
+)
+
+// WithNegativeCache keeps the keys marked absent with SetMiss in a
+// separate region holding up to maxEntries keys for expire each, so
+// they never evict cached values.
+func WithNegativeCache(maxEntries int, expire time.Duration) Option {
+	return func(c *Cache) {
+		c.negative = New(maxEntries, expire)
+	}
+}
+
+// SetMiss marks key as known to be absent, removing any value
+// stored under it. Setting a value for key clears the mark. SetMiss
+// has no effect unless the cache was created WithNegativeCache.
+func (c *Cache) SetMiss(key string) {
+	if c.negative == nil {
+		return
+	}
+	c.Delete(key)
+	c.negative.Set(key, struct{}{})
+}
+
+// Lookup is like Get but tells apart keys marked absent with
+// SetMiss from keys the cache knows nothing about.
+func (c *Cache) Lookup(key string) (value interface{}, result LookupResult) {
+	if value, ok := c.Get(key); ok {
+		return value, Hit
+	}
+	if c.negative != nil && c.negative.Contains(key) {
+		return nil, KnownMiss
+	}
+	return nil, Miss
+}
@@ -0,0 +1,31 @@
+package cache2go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNegativeCache(t *testing.T) {
+	cache := New(2, 0, WithNegativeCache(1, time.Hour))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.SetMiss("x")
+	cache.SetMiss("y")
+	if cache.Len() != 2 {
+		t.Error("misses should not evict cached values: ", cache.Len())
+	}
+	if _, r := cache.Lookup("x"); r != Miss {
+		t.Error("Error evicting negative entries independently: ", r)
+	}
+	if _, r := cache.Lookup("y"); r != KnownMiss {
+		t.Error("Error looking up known miss: ", r)
+	}
+	cache.Set("y", 3)
+	if v, r := cache.Lookup("y"); r != Hit || v != 3 {
+		t.Error("setting a value should clear the miss: ", r)
+	}
+	cache.SetMiss("a")
+	if _, r := cache.Lookup("a"); r != KnownMiss {
+		t.Error("SetMiss should drop the cached value: ", r)
+	}
+}
@@ -0,0 +1,55 @@
+package cache2go
+
+// OutcomeKind tells what SetResult did with a value.
+type OutcomeKind int
+
+const (
+	// Inserted means the key was new and nothing was evicted.
+	Inserted OutcomeKind = iota
+	// Updated means the value replaced a live one. A larger value
+	// in a cache created with NewBytesLimited may still evict
+	// entries, listed in Outcome.Evicted.
+	Updated
+	// InsertedWithEviction means the key was new and entries were
+	// evicted for capacity, as listed in Outcome.Evicted. When no
+	// other entry could go, the only key evicted is the new one,
+	// which is then not in the cache.
+	InsertedWithEviction
+	// Rejected means the value was not stored, because it failed
+	// validation or the cache uses OverflowReject and is full.
+	Rejected
+)
+
+// Outcome describes what SetResult did.
+type Outcome struct {
+	Kind OutcomeKind
+	// Evicted holds the keys evicted for capacity by the write,
+	// least recently used first.
+	Evicted []string
+}
+
+// SetResult adds a value to the cache like Set and reports whether it
+// inserted a new key, updated an existing one or had to evict entries
+// to make room, so that churn can be measured without callbacks.
+// Evicted entries still go through OnEvicted.
+func (c *Cache) SetResult(key string, value interface{}) Outcome {
+	if c.validateValue(key, value) != nil {
+		return Outcome{Kind: Rejected}
+	}
+	c.lock()
+	defer c.unlock()
+	existed := c.live(key, c.now()) != nil
+	var evicted []string
+	c.evictedKeys = &evicted
+	_, _, stored := c.set(key, value, 1, 0)
+	c.evictedKeys = nil
+	switch {
+	case !stored && len(evicted) == 0:
+		return Outcome{Kind: Rejected}
+	case existed:
+		return Outcome{Kind: Updated, Evicted: evicted}
+	case len(evicted) > 0:
+		return Outcome{Kind: InsertedWithEviction, Evicted: evicted}
+	}
+	return Outcome{Kind: Inserted}
+}
@@ -0,0 +1,27 @@
+package cache2go
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSetResult(t *testing.T) {
+	cache := New(2, 0)
+	check := func(o Outcome, kind OutcomeKind, evicted string) {
+		t.Helper()
+		if o.Kind != kind || fmt.Sprint(o.Evicted) != evicted {
+			t.Error("Error reporting outcome: ", o)
+		}
+	}
+	check(cache.SetResult("a", 1), Inserted, "[]")
+	check(cache.SetResult("b", 2), Inserted, "[]")
+	check(cache.SetResult("a", 3), Updated, "[]")
+	check(cache.SetResult("c", 4), InsertedWithEviction, "[b]")
+	cache.Pin("a")
+	cache.Pin("c")
+	check(cache.SetResult("d", 5), InsertedWithEviction, "[d]")
+
+	cache = New(1, 0, WithOverflowPolicy(OverflowReject))
+	cache.Set("a", 1)
+	check(cache.SetResult("b", 2), Rejected, "[]")
+}
@@ -0,0 +1,205 @@
+package cache2go
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// snapshot is the form in which GobCodec writes the cache.
+type snapshot struct {
+	Entries []Entry
+	Stats   *Stats
+}
+
+// WithProcessStats keeps the stats of the cache local to the
+// process: Save does not write them and Load does not restore them.
+func WithProcessStats() Option {
+	return func(c *Cache) {
+		c.processStats = true
+	}
+}
+
+// Codec encodes the entries written by Save and read by Load. The
+// values of the entries must be encodable by the codec, and decoding
+// may not give back their original types, e.g. encoding/json decodes
+// all numbers as float64. A Codec that is also a StatsCodec saves
+// the stats counters along with the entries.
+type Codec interface {
+	Marshal(entries []Entry) ([]byte, error)
+	Unmarshal(data []byte) ([]Entry, error)
+}
+
+// StatsCodec is a Codec that also encodes the stats counters. stats
+// is nil when the cache uses WithProcessStats, and may be decoded as
+// nil for data holding none.
+type StatsCodec interface {
+	Codec
+	MarshalWithStats(entries []Entry, stats *Stats) ([]byte, error)
+	UnmarshalWithStats(data []byte) ([]Entry, *Stats, error)
+}
+
+// GobCodec is the StatsCodec Save and Load use by default, based on
+// encoding/gob. The concrete types of the values must be registered
+// with gob.Register.
+type GobCodec struct{}
+
+// Marshal encodes entries with encoding/gob.
+func (g GobCodec) Marshal(entries []Entry) ([]byte, error) {
+	return g.MarshalWithStats(entries, nil)
+}
+
+// Unmarshal decodes the entries encoded by Marshal or
+// MarshalWithStats.
+func (g GobCodec) Unmarshal(data []byte) ([]Entry, error) {
+	entries, _, err := g.UnmarshalWithStats(data)
+	return entries, err
+}
+
+// MarshalWithStats encodes entries and stats with encoding/gob.
+func (GobCodec) MarshalWithStats(entries []Entry, stats *Stats) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(snapshot{Entries: entries, Stats: stats}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalWithStats decodes the entries and stats encoded by
+// Marshal or MarshalWithStats.
+func (GobCodec) UnmarshalWithStats(data []byte) ([]Entry, *Stats, error) {
+	var snap snapshot
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&snap)
+	return snap.Entries, snap.Stats, err
+}
+
+// WithCodec makes Save and Load encode the cache with codec instead
+// of GobCodec, a nil codec meaning GobCodec; Load must use the codec
+// Save used. Unless codec is a
+// StatsCodec, only the entries are encoded and the stats counters are
+// not saved.
+func WithCodec(codec Codec) Option {
+	return func(c *Cache) {
+		c.codec = codec
+	}
+}
+
+// codecOrDefault returns the codec set WithCodec, or GobCodec.
+func (c *Cache) codecOrDefault() Codec {
+	if c.codec == nil {
+		return GobCodec{}
+	}
+	return c.codec
+}
+
+// Save writes the live entries and the stats counters to w using
+// the codec set WithCodec, GobCodec by default, whose requirements
+// the values must meet.
+func (c *Cache) Save(w io.Writer) error {
+	var (
+		data []byte
+		err  error
+	)
+	entries := c.ToSlice()
+	if sc, ok := c.codecOrDefault().(StatsCodec); ok {
+		var stats *Stats
+		if !c.processStats {
+			s := c.Stats()
+			stats = &s
+		}
+		data, err = sc.MarshalWithStats(entries, stats)
+	} else {
+		data, err = c.codecOrDefault().Marshal(entries)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// Load adds the entries written by Save to the cache, keeping their
+// timestamps and recency order and skipping the ones that expired
+// in the meantime. It also adds the saved counters to the stats, so
+// lifetime hit rates survive restarts.
+func (c *Cache) Load(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var (
+		entries []Entry
+		stats   *Stats
+	)
+	if sc, ok := c.codecOrDefault().(StatsCodec); ok {
+		entries, stats, err = sc.UnmarshalWithStats(data)
+	} else {
+		entries, err = c.codecOrDefault().Unmarshal(data)
+	}
+	if err != nil {
+		return err
+	}
+	entries = c.admit(entries, Gzip)
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	for i := len(entries) - 1; i >= 0; i-- {
+		c.restore(entries[i], now)
+	}
+	// the restored entries were scheduled as if set now
+	c.rebuildTTLIndex()
+	if stats != nil && !c.processStats {
+		c.hits += stats.Hits
+		c.misses += stats.Misses
+		c.evictions += stats.Evictions
+	}
+	return nil
+}
+
+// admit returns the entries of saved that the cache may restore,
+// decompressing with comp the values compressed by SetBytes if the
+// cache does not compress, and skipping those that fail to
+// decompress or to validate.
+func (c *Cache) admit(saved []Entry, comp Compressor) []Entry {
+	entries := make([]Entry, 0, len(saved))
+	for _, en := range saved {
+		var ok bool
+		if en.Value, en.Compressed, ok = c.inflate(en.Value, en.Compressed, comp); !ok {
+			continue
+		}
+		if c.validate != nil {
+			// the validator sees the values as SetBytes was given them
+			value := en.Value
+			if en.Compressed {
+				if value, ok = decompress(value, c.compressor); !ok {
+					continue
+				}
+			}
+			if c.validateValue(en.Key, value) != nil {
+				continue
+			}
+		}
+		entries = append(entries, en)
+	}
+	return entries
+}
+
+// restore stores a saved entry with its original timestamp, leaving
+// the ttlIndex to be rebuilt.
+func (c *Cache) restore(saved Entry, now time.Time) {
+	ttl := saved.TTL
+	if ttl == 0 {
+		ttl = c.expiration
+	}
+	if ttl > 0 && !now.Before(saved.Timestamp.Add(ttl)) {
+		return
+	}
+	if _, _, stored := c.set(saved.Key, saved.Value, 1, saved.TTL); stored {
+		if e, ok := c.cache[saved.Key]; ok {
+			en := e.Value.(*entry)
+			en.timestamp, en.compressed = saved.Timestamp, saved.Compressed
+		}
+	}
+}
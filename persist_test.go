@@ -0,0 +1,178 @@
+package cache2go
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSaveLoad(t *testing.T) {
+	cache := New(0, time.Hour)
+	cache.Set("a", 1)
+	cache.Set("b", "mere")
+	cache.SetWithTTL("c", 3, time.Minute)
+	cache.Get("a")
+	cache.Get("missing")
+	var buf bytes.Buffer
+	if err := cache.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved, _ := cache.EntryInfo("b")
+
+	restored := New(0, time.Hour)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if keys := restored.Keys(); len(keys) != 3 || keys[0] != "a" || keys[2] != "b" {
+		t.Error("Error restoring recency order: ", keys)
+	}
+	if info, _ := restored.EntryInfo("b"); !info.Timestamp.Equal(saved.Timestamp) {
+		t.Error("Error restoring timestamps: ", info)
+	}
+	if info, _ := restored.EntryInfo("c"); info.Expires.Sub(info.Timestamp) != time.Minute {
+		t.Error("Error restoring entry TTL: ", info)
+	}
+	if s := restored.Stats(); s.Hits != 1 || s.Misses != 1 {
+		t.Error("Error restoring stats: ", s)
+	}
+}
+
+func TestLoadProcessStats(t *testing.T) {
+	cache := New(0, 0)
+	cache.Set("a", 1)
+	cache.Get("a")
+	var buf bytes.Buffer
+	if err := cache.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(0, 0, WithProcessStats())
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if s := restored.Stats(); s.Hits != 0 || s.Len != 1 {
+		t.Error("process stats should not be restored: ", s)
+	}
+}
+
+func TestLoadSkipsExpired(t *testing.T) {
+	cache := New(0, 0)
+	cache.SetWithTTL("short", 1, time.Millisecond)
+	cache.Set("kept", 2)
+	var buf bytes.Buffer
+	if err := cache.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	restored := New(0, 0)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Len() != 1 || !restored.Contains("kept") {
+		t.Error("Error skipping expired entries: ", restored.Keys())
+	}
+}
+
+func TestLoadRebuildsTTLIndex(t *testing.T) {
+	saved := New(0, time.Hour)
+	defer saved.Close()
+	now := time.Now()
+	for i, age := range []time.Duration{10, 50, 30, 40, 20} {
+		saved.SetWithTimestamp(string('a'+rune(i)), i, now.Add(-age*time.Minute))
+	}
+	var buf bytes.Buffer
+	if err := saved.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(0, time.Hour)
+	defer restored.Close()
+	restored.SetDebug(true)
+	if err := restored.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if keys := restored.ExpiringSoon(5); fmt.Sprint(keys) != "[b d c e a]" {
+		t.Error("Error ordering restored entries by expiration: ", keys)
+	}
+	restored.clock = func() time.Time { return now.Add(25 * time.Minute) }
+	if n := restored.SweepOnce(); n != 2 {
+		t.Error("Error sweeping restored entries: ", n)
+	}
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(entries []Entry) ([]byte, error) { return json.Marshal(entries) }
+
+func (jsonCodec) Unmarshal(data []byte) ([]Entry, error) {
+	var entries []Entry
+	err := json.Unmarshal(data, &entries)
+	return entries, err
+}
+
+func TestSaveLoadCodec(t *testing.T) {
+	for _, codec := range []Codec{GobCodec{}, jsonCodec{}} {
+		cache := New(0, time.Hour, WithCodec(codec))
+		cache.Set("a", "one")
+		cache.SetWithTTL("b", "two", time.Minute)
+		var buf bytes.Buffer
+		if err := cache.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		restored := New(0, time.Hour, WithCodec(codec))
+		if err := restored.Load(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := restored.Get("a"); v != "one" || restored.Len() != 2 {
+			t.Errorf("Error restoring with %T: %v", codec, restored.Keys())
+		}
+		if info, _ := restored.EntryInfo("b"); info.Expires.Sub(info.Timestamp) != time.Minute {
+			t.Errorf("Error restoring TTL with %T: %v", codec, info)
+		}
+		if err := restored.Load(bytes.NewReader([]byte("garbage"))); err == nil {
+			t.Errorf("%T decoded garbage", codec)
+		}
+	}
+}
+
+// jsonStatsCodec is jsonCodec saving the stats too.
+type jsonStatsCodec struct{ jsonCodec }
+
+type jsonSnapshot struct {
+	Entries []Entry
+	Stats   *Stats
+}
+
+func (jsonStatsCodec) MarshalWithStats(entries []Entry, stats *Stats) ([]byte, error) {
+	return json.Marshal(jsonSnapshot{entries, stats})
+}
+
+func (jsonStatsCodec) UnmarshalWithStats(data []byte) ([]Entry, *Stats, error) {
+	var snap jsonSnapshot
+	err := json.Unmarshal(data, &snap)
+	return snap.Entries, snap.Stats, err
+}
+
+func TestSaveLoadCodecStats(t *testing.T) {
+	for _, pair := range [][2]Codec{{nil, GobCodec{}}, {GobCodec{}, nil}, {jsonStatsCodec{}, jsonStatsCodec{}}, {jsonCodec{}, jsonCodec{}}} {
+		cache := New(0, 0, WithCodec(pair[0]))
+		cache.Set("a", "one")
+		cache.Get("a")
+		var buf bytes.Buffer
+		if err := cache.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+		restored := New(0, 0, WithCodec(pair[1]))
+		if err := restored.Load(&buf); err != nil {
+			t.Fatalf("Error loading %T with %T: %v", pair[0], pair[1], err)
+		}
+		hits := uint64(1)
+		if _, ok := pair[0].(jsonCodec); ok {
+			// a plain Codec saves no stats
+			hits = 0
+		}
+		if v, _ := restored.Peek("a"); v != "one" || restored.Stats().Hits != hits {
+			t.Errorf("Error restoring %T with %T: %v", pair[0], pair[1], restored.Stats())
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package cache2go
+
+// Pin exempts the entry under key from eviction when the cache is
+// full; it still expires and can be deleted. Pin reports whether the
+// key was found. Setting a pinned key keeps it pinned.
+//
+// When every other entry is pinned, a new entry has nothing to make
+// room for it and is evicted right away, or rejected under
+// OverflowReject; the cache never grows past maxEntries.
+func (c *Cache) Pin(key string) bool {
+	return c.setPinned(key, true)
+}
+
+// Unpin makes the entry under key evictable again and reports
+// whether the key was found.
+func (c *Cache) Unpin(key string) bool {
+	return c.setPinned(key, false)
+}
+
+func (c *Cache) setPinned(key string, pinned bool) bool {
+	c.lock()
+	defer c.unlock()
+	e := c.live(key, c.now())
+	if e == nil {
+		return false
+	}
+	e.Value.(*entry).pinned = pinned
+	return true
+}
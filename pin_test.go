@@ -0,0 +1,57 @@
+package cache2go
+
+import "testing"
+
+func TestPin(t *testing.T) {
+	cache := New(2, 0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	if !cache.Pin("a") || cache.Pin("missing") {
+		t.Fatal("Error reporting pinned keys")
+	}
+	cache.Set("c", 3)
+	if !cache.Contains("a") || cache.Contains("b") {
+		t.Error("pinned entry evicted: ", cache.Keys())
+	}
+	cache.Pin("c")
+	cache.Set("d", 4)
+	if cache.Len() != 2 || cache.Contains("d") {
+		t.Error("new entry not evicted with every other entry pinned: ", cache.Keys())
+	}
+	cache.Unpin("a")
+	cache.Set("e", 5)
+	if cache.Contains("a") || !cache.Contains("c") || !cache.Contains("e") {
+		t.Error("unpinned entry not evicted: ", cache.Keys())
+	}
+}
+
+func TestSetEvictingItself(t *testing.T) {
+	pinned := func() *Cache {
+		cache := New(1, 0, WithCompression(1, nil))
+		cache.Set("a", 1)
+		cache.Pin("a")
+		return cache
+	}
+	held := func() *Cache {
+		cache := New(1, 0, WithCompression(1, nil))
+		cache.Set("a", 1)
+		cache.GetWithRelease("a")
+		return cache
+	}
+	for _, full := range []func() *Cache{pinned, held} {
+		cache := full()
+		if cache.SetWithResult("b", 2) {
+			t.Error("SetWithResult reported an entry evicted right away as stored")
+		}
+		if v, loaded := cache.LoadOrStore("b", 2); v != nil || loaded {
+			t.Error("LoadOrStore returned an entry evicted right away: ", v, loaded)
+		}
+		if _, ok := cache.SetWithVersion("b", 2, 0); ok {
+			t.Error("SetWithVersion reported an entry evicted right away as stored")
+		}
+		cache.SetBytes("b", []byte("bytes"))
+		if cache.Contains("b") || !cache.Contains("a") {
+			t.Error("Error evicting the new entry: ", cache.Keys())
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package cache2go
+
+import "container/heap"
+
+// evictionPolicy selects how the cache picks the entry to evict.
+type evictionPolicy int
+
+const (
+	// policyLRU evicts the least recently used entry, walking the
+	// lruIndex list.
+	policyLRU evictionPolicy = iota
+	// policyCost evicts by GreedyDual score.
+	policyCost
+	// policyLRUK evicts by the time of the K-th most recent access.
+	policyLRUK
+)
+
+// prioritize updates the position of en in the eviction order after
+// it was set or read.
+func (c *Cache) prioritize(en *entry) {
+	switch c.policy {
+	case policyCost:
+		en.score = c.inflation + en.cost
+	case policyLRUK:
+		if len(en.history) == c.lrukK {
+			copy(en.history, en.history[1:])
+			en.history = en.history[:len(en.history)-1]
+		}
+		en.history = append(en.history, en.lastAccess)
+	}
+	if en.evictIndex < 0 {
+		heap.Push(&c.evictIndex, en)
+	} else {
+		heap.Fix(&c.evictIndex, en.evictIndex)
+	}
+}
+
+// nextByPriority returns the entry to evict according to the
+// eviction order, other than keep. Evictable entries come first,
+// then those that are only too young; entries in use, pinned or
+// vetoed are skipped.
+func (c *Cache) nextByPriority(keep *entry) *entry {
+	h := c.evictIndex.entries
+	// the best candidate is the heap root, or the best of its
+	// children when the root is keep
+	first, last := 0, 0
+	if len(h) > 0 && h[0] == keep {
+		first, last = 1, 2
+	}
+	var best *entry
+	for i := first; i <= last && i < len(h); i++ {
+		if best == nil || c.evictIndex.less(h[i], best) {
+			best = h[i]
+		}
+	}
+	now := c.now()
+	if best != nil && c.evictable(best, now) && !c.spared(best, keep) {
+		return best
+	}
+	var young *entry
+	best = nil
+	for _, en := range h {
+		switch {
+		case c.spared(en, keep):
+		case c.evictable(en, now):
+			if best == nil || c.evictIndex.less(en, best) {
+				best = en
+			}
+		case young == nil || c.evictIndex.less(en, young):
+			young = en
+		}
+	}
+	if best == nil {
+		return young
+	}
+	return best
+}
+
+// victimHeap orders entries by less, the next one to evict first.
+type victimHeap struct {
+	entries []*entry
+	less    func(a, b *entry) bool
+}
+
+func (h *victimHeap) Len() int { return len(h.entries) }
+
+func (h *victimHeap) Less(i, j int) bool { return h.less(h.entries[i], h.entries[j]) }
+
+func (h *victimHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].evictIndex = i
+	h.entries[j].evictIndex = j
+}
+
+func (h *victimHeap) Push(x interface{}) {
+	en := x.(*entry)
+	en.evictIndex = len(h.entries)
+	h.entries = append(h.entries, en)
+}
+
+func (h *victimHeap) Pop() interface{} {
+	n := len(h.entries)
+	en := h.entries[n-1]
+	h.entries[n-1] = nil
+	en.evictIndex = -1
+	h.entries = h.entries[:n-1]
+	return en
+}
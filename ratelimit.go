@@ -0,0 +1,31 @@
+package cache2go
+
+import "time"
+
+// Allow counts a request for key against a limit of requests per
+// fixed window starting with the first request. It reports whether
+// the request is within the limit; rejected requests are not
+// counted. The counter is stored under key as an int, so Get
+// returns how many requests were allowed in the current window,
+// and it expires with the window. Evicting the key for capacity
+// resets its window.
+func (c *Cache) Allow(key string, limit int, window time.Duration) bool {
+	c.lock()
+	defer c.unlock()
+	if e, hit := c.cache[key]; hit {
+		en := e.Value.(*entry)
+		if n, ok := en.value.(int); ok && c.now().Before(en.expires) {
+			c.access(e)
+			if n >= limit {
+				return false
+			}
+			en.value = n + 1
+			return true
+		}
+	}
+	if limit < 1 {
+		return false
+	}
+	_, _, stored := c.set(key, 1, 1, window)
+	return stored
+}
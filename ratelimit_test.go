@@ -0,0 +1,28 @@
+package cache2go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	cache := New(0, 0)
+	for i := 0; i < 3; i++ {
+		if !cache.Allow("client", 3, 10*time.Millisecond) {
+			t.Error("Error allowing request within the limit: ", i)
+		}
+	}
+	if cache.Allow("client", 3, 10*time.Millisecond) {
+		t.Error("Error rejecting request over the limit")
+	}
+	if n, _ := cache.Peek("client"); n != 3 {
+		t.Error("Error counting requests: ", n)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if cache.Contains("client") {
+		t.Error("Error expiring the window")
+	}
+	if !cache.Allow("client", 3, 10*time.Millisecond) {
+		t.Error("Error allowing request in a new window")
+	}
+}
@@ -0,0 +1,68 @@
+package cache2go
+
+import "sort"
+
+// SimulateResize returns the keys that would be evicted, in eviction
+// order, if the capacity of the cache were n, without changing
+// anything. It follows the eviction policy of the cache: pinned
+// entries and entries in use are kept, and entries younger than the
+// minimum retention go last. OnBeforeEvict is not asked. A zero n
+// means no limit.
+func (c *Cache) SimulateResize(n int) []string {
+	c.rlock()
+	defer c.RUnlock()
+	excess := c.lruIndex.Len() - n
+	if n <= 0 || excess <= 0 {
+		return nil
+	}
+	var order []*entry
+	if c.policy == policyLRU {
+		for e := c.lruIndex.Back(); e != nil; e = e.Prev() {
+			order = append(order, e.Value.(*entry))
+		}
+	} else {
+		order = append(order, c.evictIndex.entries...)
+		sort.Slice(order, func(i, j int) bool { return c.evictIndex.less(order[i], order[j]) })
+	}
+	now := c.now()
+	keys := make([]string, 0, excess)
+	// evictable entries first, then the young ones
+	for _, young := range []bool{false, true} {
+		for _, en := range order {
+			if len(keys) == excess {
+				return keys
+			}
+			if en.refs > 0 || en.pinned || c.evictable(en, now) == young {
+				continue
+			}
+			keys = append(keys, en.key)
+		}
+	}
+	return keys
+}
+
+// Reserve evicts entries until there is room for n new ones, so that
+// a bulk insert following it does not evict as it goes. It reports
+// whether there is room: under OverflowReject Reserve only removes
+// expired entries, and pinned entries or entries in use may leave
+// too little room. The room is not held for the caller. A cache
+// without limit always has room.
+func (c *Cache) Reserve(n int) bool {
+	c.lock()
+	defer c.unlock()
+	if c.maxEntries == 0 {
+		return true
+	}
+	if n > c.maxEntries {
+		return false
+	}
+	c.removeExpired(c.now())
+	for c.overflow == OverflowEvict && c.lruIndex.Len() > c.maxEntries-n {
+		before := c.lruIndex.Len()
+		c.removeOldest(nil)
+		if c.lruIndex.Len() == before {
+			break
+		}
+	}
+	return c.lruIndex.Len() <= c.maxEntries-n
+}
@@ -0,0 +1,49 @@
+package cache2go
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSimulateResize(t *testing.T) {
+	cache := New(0, 0)
+	for i := 0; i < 5; i++ {
+		cache.Set(fmt.Sprint(i), i)
+	}
+	cache.Get("0")
+	cache.Pin("1")
+	if keys := cache.SimulateResize(2); fmt.Sprint(keys) != "[2 3 4]" {
+		t.Error("Error simulating resize: ", keys)
+	}
+	if keys := cache.SimulateResize(5); keys != nil {
+		t.Error("no keys should be evicted when the cache fits: ", keys)
+	}
+	if cache.Len() != 5 || cache.Keys()[0] != "0" {
+		t.Error("SimulateResize changed the cache: ", cache.Keys())
+	}
+}
+
+func TestReserve(t *testing.T) {
+	cache := New(5, 0)
+	for i := 0; i < 5; i++ {
+		cache.Set(fmt.Sprint(i), i)
+	}
+	cache.Pin("0")
+	if !cache.Reserve(3) || fmt.Sprint(cache.Keys()) != "[4 0]" {
+		t.Error("Error reserving room: ", cache.Keys())
+	}
+	if cache.Reserve(5) || cache.Len() != 1 {
+		t.Error("pinned entry should leave too little room: ", cache.Keys())
+	}
+	if cache.Reserve(6) {
+		t.Error("reserved more than the capacity")
+	}
+	cache = New(2, 0, WithOverflowPolicy(OverflowReject))
+	cache.Set("a", 1)
+	if !cache.Reserve(1) || cache.Reserve(2) || cache.Len() != 1 {
+		t.Error("Error reserving room under OverflowReject: ", cache.Keys())
+	}
+	if !New(0, 0).Reserve(1000) {
+		t.Error("cache without limit should always have room")
+	}
+}
@@ -0,0 +1,33 @@
+package cache2go
+
+import "time"
+
+// SetWithExpireAndCallback is like SetWithTTL but also calls fn with
+// the key and value once the entry expires, turning the cache into a
+// simple delayed task scheduler. Deleting, flushing or setting the
+// key again before it expires, or evicting it for capacity, cancels
+// fn. A zero ttl uses the cache expiration, and fn never runs if
+// neither is set.
+//
+// fn never runs before the expiration. With SweepFull it runs when
+// the cleanup goroutine wakes up at the expiration, usually within a
+// few milliseconds of it, but later under load since expired entries
+// are removed under the lock; with SweepSampled it runs within a few
+// sampledSweepInterval. With SweepTimers the timer of the entry runs
+// it right at the expiration, only delayed by waiting for the lock,
+// and with SweepWheel it runs up to a wheelTick after it, when the
+// slot of the expiration is swept. After Close, only a lookup of the
+// expired key or SweepOnce triggers it. fn runs without the cache
+// locked.
+func (c *Cache) SetWithExpireAndCallback(key string, value interface{}, ttl time.Duration, fn func(key string, value interface{})) {
+	if c.validateValue(key, value) != nil {
+		return
+	}
+	c.lock()
+	defer c.unlock()
+	if _, _, stored := c.set(key, value, 1, ttl); stored {
+		if e, ok := c.cache[key]; ok {
+			e.Value.(*entry).onExpire = fn
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package cache2go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetWithExpireAndCallback(t *testing.T) {
+	cache := New(0, 0)
+	defer cache.Close()
+	fired := make(chan string, 10)
+	fn := func(key string, value interface{}) {
+		fired <- key
+		// the cache is not locked
+		cache.Set("after-"+key, value)
+	}
+	start := time.Now()
+	cache.SetWithExpireAndCallback("task", 1, 20*time.Millisecond, fn)
+	cache.SetWithExpireAndCallback("cancelled", 2, 20*time.Millisecond, fn)
+	cache.SetWithExpireAndCallback("replaced", 3, 20*time.Millisecond, fn)
+	cache.Delete("cancelled")
+	cache.SetWithTTL("replaced", 4, 20*time.Millisecond)
+	select {
+	case key := <-fired:
+		if key != "task" {
+			t.Error("cancelled callback fired: ", key)
+		}
+		if time.Since(start) < 20*time.Millisecond {
+			t.Error("callback fired before the expiration")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expire callback did not fire")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(fired) != 0 {
+		t.Error("cancelled callback fired: ", <-fired)
+	}
+	if !cache.Contains("after-task") {
+		t.Error("callback could not use the cache")
+	}
+}
@@ -0,0 +1,87 @@
+package cache2go
+
+import "time"
+
+// Sharded spreads keys over several independent caches so that
+// operations on different shards do not contend for the same lock.
+type Sharded struct {
+	shards []*Cache
+	hash   func(key string) uint32
+}
+
+// NewSharded creates a cache made of n shards. Keys are assigned to
+// shards with hash, or with 32 bit FNV-1a if hash is nil. The
+// maxEntries limit is split evenly across the shards, rounding up,
+// and the options apply to every shard.
+func NewSharded(n int, hash func(key string) uint32, maxEntries int, expire time.Duration, opts ...Option) *Sharded {
+	if n < 1 {
+		n = 1
+	}
+	if hash == nil {
+		hash = fnv32a
+	}
+	s := &Sharded{shards: make([]*Cache, n), hash: hash}
+	perShard := (maxEntries + n - 1) / n
+	for i := range s.shards {
+		s.shards[i] = New(perShard, expire, opts...)
+	}
+	return s
+}
+
+func fnv32a(key string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return h
+}
+
+// Shard returns the cache holding key.
+func (s *Sharded) Shard(key string) *Cache {
+	return s.shards[s.hash(key)%uint32(len(s.shards))]
+}
+
+// Set adds a value to the cache.
+func (s *Sharded) Set(key string, value interface{}) {
+	s.Shard(key).Set(key, value)
+}
+
+// Get looks up a key's value from the cache.
+func (s *Sharded) Get(key string) (value interface{}, ok bool) {
+	return s.Shard(key).Get(key)
+}
+
+// Contains reports whether key is in the cache.
+func (s *Sharded) Contains(key string) bool {
+	return s.Shard(key).Contains(key)
+}
+
+// Delete removes the provided key from the cache.
+func (s *Sharded) Delete(key string) {
+	s.Shard(key).Delete(key)
+}
+
+// Len returns the number of items in all the shards.
+func (s *Sharded) Len() int {
+	n := 0
+	for _, c := range s.shards {
+		n += c.Len()
+	}
+	return n
+}
+
+// Flush empties every shard.
+func (s *Sharded) Flush() {
+	for _, c := range s.shards {
+		c.Flush()
+	}
+}
+
+// Close stops the background goroutines of every shard, as
+// Cache.Close does.
+func (s *Sharded) Close() {
+	for _, c := range s.shards {
+		c.Close()
+	}
+}
@@ -0,0 +1,66 @@
+package cache2go
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSharded(t *testing.T) {
+	cache := NewSharded(4, nil, 0, 0)
+	for i := 0; i < 100; i++ {
+		cache.Set(fmt.Sprintf("%d", i), i)
+	}
+	if cache.Len() != 100 {
+		t.Error("Error storing in shards: ", cache.Len())
+	}
+	if v, ok := cache.Get("42"); !ok || v != 42 {
+		t.Error("Error retrieving from shards: ", v)
+	}
+	cache.Delete("42")
+	if cache.Contains("42") {
+		t.Error("Error deleting from shards")
+	}
+	cache.Flush()
+	if cache.Len() != 0 {
+		t.Error("Error flushing shards: ", cache.Len())
+	}
+}
+
+func TestShardedCustomHash(t *testing.T) {
+	// keep each tenant on its own shard
+	tenant := func(key string) uint32 {
+		if strings.HasPrefix(key, "b/") {
+			return 1
+		}
+		return 0
+	}
+	cache := NewSharded(2, tenant, 4, 0)
+	for i := 0; i < 10; i++ {
+		cache.Set(fmt.Sprintf("a/%d", i), i)
+	}
+	cache.Set("b/1", 1)
+	if cache.Shard("b/1").Len() != 1 || cache.Shard("a/1").Len() != 2 {
+		t.Error("Error selecting shards with custom hash")
+	}
+}
+
+func TestShardedClose(t *testing.T) {
+	cache := NewSharded(4, nil, 0, time.Hour)
+	cache.Close()
+	cache.Close()
+	for _, shard := range cache.shards {
+		shard.Lock()
+		running := shard.stop != nil
+		shard.Unlock()
+		if running {
+			t.Error("Close left a shard cleanup running")
+		}
+	}
+	tiered := NewTiered(New(0, time.Hour), New(0, time.Hour), WriteThrough, false)
+	tiered.Close()
+	if tiered.l1.stop != nil || tiered.l2.stop != nil {
+		t.Error("Close left a tier cleanup running")
+	}
+}
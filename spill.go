@@ -0,0 +1,43 @@
+package cache2go
+
+// OverflowStore is a slower tier, such as a disk, receiving the
+// entries evicted from a cache created WithOverflowStore.
+type OverflowStore interface {
+	// Store saves an entry evicted for capacity.
+	Store(key string, value interface{})
+	// Load returns a value saved with Store, or false if there is
+	// none for key.
+	Load(key string) (value interface{}, ok bool)
+}
+
+// WithOverflowStore hands the entries evicted for capacity to s
+// instead of discarding them, and makes Get look up keys missing
+// from the cache in s, storing the values found back into the cache.
+// The entries evicted to make room for them go to s in turn. Store
+// and Load run without the cache locked, so a Get racing with the
+// eviction of its key may miss it. Removals other than for capacity,
+// such as Delete or expiration, do not reach s, and the hit and miss
+// stats only count the lookups in memory.
+func WithOverflowStore(s OverflowStore) Option {
+	return func(c *Cache) {
+		c.spill = s
+	}
+}
+
+// unspill looks up key in the overflow store, promoting it back into
+// the cache if found.
+func (c *Cache) unspill(key string) (value interface{}, ok bool) {
+	c.safely(func() { value, ok = c.spill.Load(key) })
+	if !ok || c.validateValue(key, value) != nil {
+		return value, ok
+	}
+	c.lock()
+	defer c.unlock()
+	// keep a value set meanwhile
+	if e := c.live(key, c.now()); e != nil {
+		c.access(e)
+		return e.Value.(*entry).value, true
+	}
+	c.set(key, value, 1, 0)
+	return value, true
+}
@@ -0,0 +1,47 @@
+package cache2go
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+type mapStore struct {
+	mu sync.Mutex
+	m  map[string]interface{}
+}
+
+func (s *mapStore) Store(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[key] = value
+}
+
+func (s *mapStore) Load(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.m[key]
+	delete(s.m, key)
+	return v, ok
+}
+
+func TestOverflowStore(t *testing.T) {
+	store := &mapStore{m: make(map[string]interface{})}
+	cache := New(2, 0, WithOverflowStore(store))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	if fmt.Sprint(store.m) != "map[a:1]" {
+		t.Error("Error spilling evicted entry: ", store.m)
+	}
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Error("Error loading spilled entry: ", v, ok)
+	}
+	if fmt.Sprint(cache.Keys()) != "[a c]" || fmt.Sprint(store.m) != "map[b:2]" {
+		t.Error("Error promoting spilled entry: ", cache.Keys(), store.m)
+	}
+	cache.Delete("a")
+	if _, ok := cache.Get("a"); ok || len(store.m) != 1 {
+		t.Error("deleted entry spilled: ", store.m)
+	}
+}
@@ -0,0 +1,33 @@
+package cache2go
+
+import "time"
+
+// WithStaleGrace keeps serving entries for grace after they expire,
+// so that a stale value can be returned while a fresh one is loaded
+// instead of missing. Get and the other lookups return these values
+// as usual; GetWithStale tells them apart. The entries are removed,
+// and their callbacks run, once the grace is over. Since the grace is
+// the same for every entry, the expiration index keeps its order and
+// the cleanup just runs grace later.
+func WithStaleGrace(grace time.Duration) Option {
+	return func(c *Cache) {
+		c.staleGrace = grace
+	}
+}
+
+// GetWithStale is like Get but also reports whether the value is
+// past its expiration and only served during the stale grace.
+func (c *Cache) GetWithStale(key string) (value interface{}, stale, ok bool) {
+	c.lock()
+	defer c.unlock()
+	now := c.now()
+	e := c.live(key, now)
+	if e == nil {
+		c.lookup(false, now)
+		return nil, false, false
+	}
+	c.access(e)
+	c.lookup(true, now)
+	en := e.Value.(*entry)
+	return en.value, c.stale(en, now), true
+}
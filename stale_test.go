@@ -0,0 +1,29 @@
+package cache2go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStaleGrace(t *testing.T) {
+	cache := New(0, 20*time.Millisecond, WithStaleGrace(40*time.Millisecond))
+	defer cache.Close()
+	cache.Set("a", 1)
+	if v, stale, ok := cache.GetWithStale("a"); !ok || stale || v != 1 {
+		t.Error("Error getting fresh value: ", v, stale, ok)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if v, stale, ok := cache.GetWithStale("a"); !ok || !stale || v != 1 {
+		t.Error("Error serving stale value during the grace: ", v, stale, ok)
+	}
+	if v, ok := cache.Get("a"); !ok || v != 1 {
+		t.Error("Get should serve stale values: ", v, ok)
+	}
+	if _, stale := cache.GetManyStale([]string{"a"}); stale["a"] != 1 {
+		t.Error("GetManyStale should report stale values: ", stale)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if cache.Len() != 0 {
+		t.Error("entry not removed after the grace")
+	}
+}
@@ -0,0 +1,121 @@
+package cache2go
+
+import (
+	"expvar"
+	"time"
+)
+
+// Stats is a snapshot of the cache counters.
+type Stats struct {
+	Len int
+	// Hits and Misses count the lookups done with Get.
+	Hits   uint64
+	Misses uint64
+	// Evictions counts the entries removed because the cache
+	// was full or because they expired.
+	Evictions uint64
+}
+
+// Stats returns the current cache counters.
+func (c *Cache) Stats() Stats {
+	c.rlock()
+	defer c.RUnlock()
+	return Stats{
+		Len:       c.lruIndex.Len(),
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+	}
+}
+
+const (
+	hitRateBucket  = time.Second
+	hitRateBuckets = 300
+)
+
+// hitRing counts the lookups of each of the last hitRateBuckets
+// intervals of hitRateBucket.
+type hitRing [hitRateBuckets]struct {
+	interval     int64
+	hits, misses uint64
+}
+
+// lookup counts a hit or a miss at now.
+func (c *Cache) lookup(hit bool, now time.Time) {
+	if c.recent == nil {
+		c.recent = new(hitRing)
+	}
+	i := now.UnixNano() / int64(hitRateBucket)
+	b := &c.recent[i%hitRateBuckets]
+	if b.interval != i {
+		b.interval, b.hits, b.misses = i, 0, 0
+	}
+	if hit {
+		c.hits++
+		b.hits++
+	} else {
+		c.misses++
+		b.misses++
+	}
+}
+
+// HitRate returns the fraction of the lookups done with Get in the
+// last window that were hits, or zero if there were none. Lookups
+// are counted per second over the last five minutes, so window is
+// rounded up to whole seconds and capped at five minutes.
+func (c *Cache) HitRate(window time.Duration) float64 {
+	c.rlock()
+	defer c.RUnlock()
+	if c.recent == nil {
+		return 0
+	}
+	n := int64((window + hitRateBucket - 1) / hitRateBucket)
+	if n > hitRateBuckets {
+		n = hitRateBuckets
+	}
+	last := c.now().UnixNano() / int64(hitRateBucket)
+	var hits, misses uint64
+	for _, b := range c.recent {
+		if b.interval > last-n && b.interval <= last {
+			hits += b.hits
+			misses += b.misses
+		}
+	}
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// WithStatsSampler calls fn with the cache stats every interval,
+// from a goroutine that runs until Close. A zero interval disables
+// the sampler.
+func WithStatsSampler(interval time.Duration, fn func(Stats)) Option {
+	return func(c *Cache) {
+		c.sampleEvery = interval
+		c.onSample = fn
+	}
+}
+
+func (c *Cache) sample(done chan struct{}) {
+	ticker := time.NewTicker(c.sampleEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			stats := c.Stats()
+			c.safely(func() { c.onSample(stats) })
+		}
+	}
+}
+
+// PublishExpvar exports the cache stats as an expvar under name,
+// making them available at /debug/vars. Like expvar.Publish, it
+// panics if name is already registered.
+func (c *Cache) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return c.Stats()
+	}))
+}
@@ -0,0 +1,95 @@
+package cache2go
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	cache := New(1, 0)
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("b")
+	cache.Set("b", 2)
+	if s := cache.Stats(); s != (Stats{Len: 1, Hits: 1, Misses: 1, Evictions: 1}) {
+		t.Error("Error counting stats: ", s)
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+	cache := New(0, 0)
+	// expvar names cannot be reused, e.g. with -count
+	name := fmt.Sprintf("cache2go_test_%d", time.Now().UnixNano())
+	for expvar.Get(name) != nil {
+		name += "_"
+	}
+	cache.PublishExpvar(name)
+	cache.Set("a", 1)
+	var s Stats
+	if err := json.Unmarshal([]byte(expvar.Get(name).String()), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len != 1 {
+		t.Error("published stats do not reflect live state: ", s)
+	}
+}
+
+func TestStatsSampler(t *testing.T) {
+	samples := make(chan Stats, 100)
+	cache := New(0, 0, WithStatsSampler(time.Millisecond, func(s Stats) { samples <- s }))
+	cache.Set("a", 1)
+	for s := range samples {
+		if s.Len == 1 {
+			break
+		}
+	}
+	cache.Close()
+	cache.Close()
+	time.Sleep(5 * time.Millisecond)
+	for len(samples) > 0 {
+		<-samples
+	}
+	time.Sleep(5 * time.Millisecond)
+	if len(samples) != 0 {
+		t.Error("sampler still running after Close")
+	}
+}
+
+func TestCloseStopsCleanup(t *testing.T) {
+	cache := New(0, time.Millisecond)
+	cache.Close()
+	cache.Set("a", 1)
+	time.Sleep(5 * time.Millisecond)
+	if cache.Len() != 1 {
+		t.Error("expired entries swept after Close")
+	}
+	if _, ok := cache.Get("a"); ok {
+		t.Error("expired entry returned after Close")
+	}
+}
+
+func TestHitRate(t *testing.T) {
+	cache := New(0, 0)
+	if r := cache.HitRate(time.Minute); r != 0 {
+		t.Error("hit rate without lookups: ", r)
+	}
+	cache.Set("a", 1)
+	// lookups two minutes ago were all hits
+	past := time.Now().Add(-2 * time.Minute)
+	cache.Lock()
+	for i := 0; i < 6; i++ {
+		cache.lookup(true, past)
+	}
+	cache.Unlock()
+	cache.Get("a")
+	cache.Get("b")
+	if r := cache.HitRate(time.Minute); r != 0.5 {
+		t.Error("Error computing recent hit rate: ", r)
+	}
+	if r := cache.HitRate(time.Hour); r != 0.875 {
+		t.Error("Error computing hit rate over the whole ring: ", r)
+	}
+}
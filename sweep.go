@@ -0,0 +1,142 @@
+package cache2go
+
+import (
+	"math/rand"
+	"time"
+)
+
+// SweepStrategy decides how the cleanup goroutine finds expired
+// entries.
+type SweepStrategy int
+
+const (
+	// SweepFull wakes up when the next entry expires and removes
+	// every expired entry.
+	SweepFull SweepStrategy = iota
+	// SweepSampled wakes up every sampledSweepInterval and checks
+	// random entries among those that expire, removing the expired
+	// ones and repeating while more than a quarter of the sample
+	// had expired. Each run is cheap even if many entries expire at
+	// once; entries not reached yet are still reported as missing
+	// by the lookups.
+	SweepSampled
+	// SweepTimers gives each expiring entry its own timer, removing
+	// it right at its expiration instead of running a cleanup
+	// goroutine. This is the most precise strategy, but each entry
+	// costs a runtime timer, which is reset whenever its expiration
+	// changes, including on every Get with an idle timeout. OnSweep
+	// and OnExpiredBatch are not called.
+	SweepTimers
+	// SweepWheel buckets the expiring entries by expiration into
+	// the slots of a hashed timing wheel, waking up every wheelTick
+	// to remove the expired entries of the slots whose time came.
+	// Scheduling an entry takes constant time instead of the
+	// logarithmic time of keeping the entries ordered, which may
+	// pay off with many entries set concurrently with their own
+	// TTL; see the SetTTL benchmarks. Expired
+	// entries are removed up to a wheelTick late, which lookups do
+	// not see, and ExpiringSoon and LenByState take time
+	// proportional to the number of expiring entries.
+	SweepWheel
+)
+
+const (
+	sampledSweepInterval = 100 * time.Millisecond
+	sweepSample          = 20
+)
+
+// WithSweepStrategy sets how expired entries are cleaned up. The
+// default is SweepFull.
+func WithSweepStrategy(s SweepStrategy) Option {
+	return func(c *Cache) {
+		c.sweep = s
+	}
+}
+
+// WithSweepInterval bounds how often the cleanup goroutine wakes up.
+// It sleeps at least min after each run, even when an entry expires
+// sooner, which then stays until the next run unless looked up, and
+// at most max. Setting an entry that expires first still wakes it
+// up with SweepFull. While nothing is due to expire it backs off, doubling
+// its sleep after each run up to max; an entry set meanwhile wakes it
+// up as usual. A zero max means no cap and no backoff, and a max
+// below min is raised to min. OnSweep observes each run.
+func WithSweepInterval(min, max time.Duration) Option {
+	return func(c *Cache) {
+		if max > 0 && max < min {
+			max = min
+		}
+		c.minSweep, c.maxSweep = min, max
+	}
+}
+
+// boundSweep applies WithSweepInterval to the time wait the cleanup
+// would sleep, given whether it is idle and the previous idle sleep,
+// and returns the sleep along with the new backoff.
+func (c *Cache) boundSweep(wait time.Duration, idle bool, backoff time.Duration) (time.Duration, time.Duration) {
+	if idle && c.maxSweep > 0 && backoff > 0 {
+		wait = 2 * backoff
+	}
+	if wait < c.minSweep {
+		wait = c.minSweep
+	}
+	if c.maxSweep > 0 && wait > c.maxSweep {
+		wait = c.maxSweep
+	}
+	if !idle {
+		return wait, 0
+	}
+	return wait, wait
+}
+
+// removeSampled removes the expired entries found by sampling and
+// returns how many there were.
+func (c *Cache) removeSampled(now time.Time) int {
+	n := 0
+	for len(c.ttlIndex) > 0 {
+		found := 0
+		for i := 0; i < sweepSample && len(c.ttlIndex) > 0; i++ {
+			en := c.ttlIndex[rand.Intn(len(c.ttlIndex))]
+			if c.expired(en, now) {
+				c.expire(c.cache[en.key])
+				found++
+			}
+		}
+		n += found
+		if 4*found <= sweepSample {
+			break
+		}
+	}
+	return n
+}
+
+// arm sets the timer of en to remove it at its expiration.
+func (c *Cache) arm(en *entry) {
+	if c.closed {
+		return
+	}
+	d := en.expires.Add(c.staleGrace).Sub(c.now())
+	if en.timer != nil {
+		en.timer.Reset(d)
+		return
+	}
+	en.timer = time.AfterFunc(d, func() { c.fire(en) })
+}
+
+// disarm stops the timer of en, if any.
+func (c *Cache) disarm(en *entry) {
+	if en.timer != nil {
+		en.timer.Stop()
+		en.timer = nil
+	}
+}
+
+// fire removes en when its timer goes off, unless it was removed or
+// its expiration changed meanwhile.
+func (c *Cache) fire(en *entry) {
+	c.lock()
+	defer c.unlock()
+	if e, ok := c.cache[en.key]; ok && e.Value.(*entry) == en && c.expired(en, c.now()) {
+		c.expire(e)
+	}
+}
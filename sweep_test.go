@@ -0,0 +1,93 @@
+package cache2go
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSampledSweep(t *testing.T) {
+	cache := New(0, 0, WithSweepStrategy(SweepSampled))
+	defer cache.Close()
+	for i := 0; i < 100; i++ {
+		cache.SetWithTTL(fmt.Sprint(i), i, time.Millisecond)
+	}
+	cache.SetWithTTL("later", 0, time.Hour)
+	cache.Set("forever", 0)
+	deadline := time.Now().Add(time.Second)
+	for cache.Len() > 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := cache.Len(); n != 2 {
+		t.Error("sampled sweep left expired entries: ", n)
+	}
+}
+
+func TestTimerSweep(t *testing.T) {
+	cache := New(0, 0, WithSweepStrategy(SweepTimers))
+	removed := make(chan time.Time, 10)
+	cache.OnEvicted(func(key string, value interface{}) {
+		removed <- time.Now()
+	})
+	start := time.Now()
+	cache.SetWithTTL("a", 1, 30*time.Millisecond)
+	cache.SetWithTTL("deleted", 2, 10*time.Millisecond)
+	cache.Delete("deleted")
+	<-removed
+	cache.SetWithTTL("b", 2, time.Hour)
+	cache.SetWithTTL("b", 2, 0)
+	select {
+	case at := <-removed:
+		if d := at.Sub(start); d < 30*time.Millisecond {
+			t.Error("entry removed before its expiration: ", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("entry not removed by its timer")
+	}
+	cache.Lock()
+	if cache.stop != nil {
+		t.Error("cleanup goroutine started with SweepTimers")
+	}
+	if en := cache.cache["b"].Value.(*entry); en.timer != nil {
+		t.Error("timer kept for entry that no longer expires")
+	}
+	cache.Unlock()
+	cache.SetWithTTL("c", 3, time.Hour)
+	cache.Flush()
+	cache.SetWithTTL("d", 4, time.Hour)
+	en := cache.cache["d"].Value.(*entry)
+	cache.Close()
+	if en.timer != nil {
+		t.Error("timer not stopped by Close")
+	}
+}
+
+func TestWithSweepInterval(t *testing.T) {
+	cache := New(0, 0, WithSweepInterval(50*time.Millisecond, time.Second))
+	defer cache.Close()
+	var got []time.Duration
+	backoff := time.Duration(0)
+	for _, run := range []struct {
+		wait time.Duration
+		idle bool
+	}{{10 * time.Millisecond, false}, {time.Hour, false}, {100 * time.Millisecond, true}, {100 * time.Millisecond, true}, {time.Hour, true}, {time.Hour, true}, {200 * time.Millisecond, false}} {
+		var wait time.Duration
+		wait, backoff = cache.boundSweep(run.wait, run.idle, backoff)
+		got = append(got, wait)
+	}
+	if fmt.Sprint(got) != "[50ms 1s 100ms 200ms 400ms 800ms 200ms]" {
+		t.Error("Error bounding the sweep interval: ", got)
+	}
+
+	runs := make(chan int, 100)
+	cache.OnSweep(func(removed int) { runs <- removed })
+	cache.SetWithTTL("a", 1, time.Millisecond)
+	deadline := time.After(time.Second)
+	for removed := 0; removed == 0; {
+		select {
+		case removed = <-runs:
+		case <-deadline:
+			t.Fatal("entry expiring soon was not swept")
+		}
+	}
+}
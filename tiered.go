@@ -0,0 +1,97 @@
+package cache2go
+
+// WritePolicy controls how a Tiered cache propagates writes to
+// its second tier.
+type WritePolicy int
+
+const (
+	// WriteThrough stores every Set in both tiers.
+	WriteThrough WritePolicy = iota
+	// WriteBack stores a Set only in the first tier. The value
+	// reaches the second tier when it is evicted from the first.
+	WriteBack
+)
+
+// Tiered layers a small, fast Cache in front of a larger one.
+type Tiered struct {
+	l1, l2 *Cache
+	policy WritePolicy
+	repair func(key string, l1, l2 interface{}) interface{}
+}
+
+// NewTiered creates a two level cache on top of l1 and l2. If
+// demote is true, entries evicted from l1 are stored in l2; this
+// is always the case for the WriteBack policy, since l2 would
+// otherwise never see those values. Only evictions for capacity
+// demote entries: those expiring, deleted or flushed from l1 are
+// gone. Demotion leaves the OnEvicted callback of l1 alone and runs
+// without l1 locked.
+func NewTiered(l1, l2 *Cache, policy WritePolicy, demote bool) *Tiered {
+	t := &Tiered{l1: l1, l2: l2, policy: policy}
+	if demote || policy == WriteBack {
+		l1.lock()
+		l1.demote = func(key string, value interface{}) {
+			l2.Set(key, value)
+		}
+		l1.unlock()
+	}
+	return t
+}
+
+// Get looks up key in l1 and then in l2. Values found in l2 are
+// promoted into l1.
+func (t *Tiered) Get(key string) (value interface{}, ok bool) {
+	var stale interface{}
+	if t.repair != nil {
+		// read before Get removes it if expired
+		stale, _ = t.l1.peekStale(key)
+	}
+	if value, ok = t.l1.Get(key); ok {
+		return
+	}
+	if value, ok = t.l2.Get(key); ok {
+		if t.repair != nil {
+			l2 := value
+			t.l1.safely(func() { value = t.repair(key, stale, l2) })
+		}
+		t.l1.Set(key, value)
+	}
+	return
+}
+
+// SetReadRepair sets a function deciding the value promoted into l1
+// when Get finds key only in l2. It receives the value l1 still holds
+// past its expiration, or nil, and the value found in l2; Get returns
+// and stores in l1 what it returns, leaving l2 unchanged. Getting the
+// expired value costs Get an extra lookup in l1. It must be
+// set before the Tiered cache is used concurrently.
+func (t *Tiered) SetReadRepair(fn func(key string, l1, l2 interface{}) interface{}) {
+	t.repair = fn
+}
+
+// Set adds a value to the cache according to the write policy.
+func (t *Tiered) Set(key string, value interface{}) {
+	t.l1.Set(key, value)
+	if t.policy == WriteThrough {
+		t.l2.Set(key, value)
+	}
+}
+
+// Delete removes the provided key from both tiers.
+func (t *Tiered) Delete(key string) {
+	t.l1.Delete(key)
+	t.l2.Delete(key)
+}
+
+// Flush empties both tiers.
+func (t *Tiered) Flush() {
+	t.l1.Flush()
+	t.l2.Flush()
+}
+
+// Close stops the background goroutines of both tiers, as
+// Cache.Close does.
+func (t *Tiered) Close() {
+	t.l1.Close()
+	t.l2.Close()
+}
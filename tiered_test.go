@@ -0,0 +1,109 @@
+package cache2go
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTieredWriteThrough(t *testing.T) {
+	l1, l2 := New(1, 0), New(0, 0)
+	tiered := NewTiered(l1, l2, WriteThrough, false)
+	tiered.Set("a", 1)
+	tiered.Set("b", 2)
+	if l1.Contains("a") || !l2.Contains("a") || !l2.Contains("b") {
+		t.Error("Error writing through to both tiers")
+	}
+	if v, ok := tiered.Get("a"); !ok || v != 1 {
+		t.Error("Error reading from the second tier: ", v)
+	}
+	if !l1.Contains("a") {
+		t.Error("Error promoting into the first tier")
+	}
+	tiered.Delete("a")
+	if _, ok := tiered.Get("a"); ok {
+		t.Error("Error deleting from both tiers")
+	}
+}
+
+func TestTieredWriteBack(t *testing.T) {
+	l1, l2 := New(1, 0), New(0, 0)
+	tiered := NewTiered(l1, l2, WriteBack, false)
+	tiered.Set("a", 1)
+	if l2.Contains("a") {
+		t.Error("write back should not store in the second tier")
+	}
+	tiered.Set("b", 2)
+	if v, ok := l2.Get("a"); !ok || v != 1 {
+		t.Error("Error demoting evicted entry: ", v)
+	}
+	tiered.Flush()
+	if l1.Len() != 0 || l2.Len() != 0 {
+		t.Error("Error flushing both tiers")
+	}
+}
+
+func TestTieredReadRepair(t *testing.T) {
+	l1, l2 := New(0, time.Hour), New(0, 0)
+	defer l1.Close()
+	tiered := NewTiered(l1, l2, WriteThrough, false)
+	tiered.SetReadRepair(func(key string, stale, fresh interface{}) interface{} {
+		if stale == nil {
+			return fresh
+		}
+		return stale.(int) + fresh.(int)
+	})
+	tiered.Set("a", 1)
+	l2.Set("a", 10)
+	l1.Lock()
+	l1.cache["a"].Value.(*entry).expires = time.Now()
+	l1.Unlock()
+	if v, ok := tiered.Get("a"); !ok || v != 11 {
+		t.Error("Error repairing promoted value: ", v)
+	}
+	if v, _ := l1.Get("a"); v != 11 {
+		t.Error("Error storing repaired value in l1: ", v)
+	}
+	if v, _ := l2.Get("a"); v != 10 {
+		t.Error("read repair changed l2: ", v)
+	}
+	l2.Set("b", 2)
+	if v, _ := tiered.Get("b"); v != 2 {
+		t.Error("Error promoting value without stale l1 value: ", v)
+	}
+}
+
+func TestTieredDemotesEvictionsOnly(t *testing.T) {
+	l1, l2 := New(1, 10*time.Millisecond), New(0, 0)
+	defer l1.Close()
+	evicted := 0
+	l1.OnEvicted(func(key string, value interface{}) { evicted++ })
+	tiered := NewTiered(l1, l2, WriteBack, false)
+	tiered.Set("a", 1)
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := tiered.Get("a"); ok || l2.Contains("a") {
+		t.Error("expired entry demoted to l2")
+	}
+	tiered.Set("b", 2)
+	l1.Delete("b")
+	if l2.Contains("b") {
+		t.Error("deleted entry demoted to l2")
+	}
+	tiered.Set("c", 3)
+	tiered.Set("d", 4)
+	if !l2.Contains("c") || evicted != 3 {
+		t.Error("Error demoting evicted entry: ", l2.Keys(), evicted)
+	}
+}
+
+func TestTieredDemotesOversized(t *testing.T) {
+	l1, l2 := NewBytesLimited(DefaultEntryOverhead+10, 0), New(0, 0)
+	tiered := NewTiered(l1, l2, WriteBack, false)
+	huge := string(make([]byte, 1000))
+	tiered.Set("huge", huge)
+	if l1.Contains("huge") {
+		t.Error("entry larger than l1 kept there")
+	}
+	if v, ok := tiered.Get("huge"); !ok || v != huge {
+		t.Error("entry larger than l1 lost from both tiers")
+	}
+}
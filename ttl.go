@@ -0,0 +1,63 @@
+package cache2go
+
+// ttlHeap orders the expiring entries by expiration time,
+// soonest first, and entries expiring at the same time by the order
+// they were set.
+type ttlHeap []*entry
+
+func (h ttlHeap) Len() int { return len(h) }
+
+func (h ttlHeap) Less(i, j int) bool { return expiresBefore(h[i], h[j]) }
+
+func expiresBefore(a, b *entry) bool {
+	if a.expires.Equal(b.expires) {
+		return a.seq < b.seq
+	}
+	return a.expires.Before(b.expires)
+}
+
+func (h ttlHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *ttlHeap) Push(x interface{}) {
+	en := x.(*entry)
+	en.index = len(*h)
+	*h = append(*h, en)
+}
+
+func (h *ttlHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	en := old[n-1]
+	old[n-1] = nil
+	en.index = -1
+	*h = old[:n-1]
+	return en
+}
+
+// positionHeap orders positions of a ttlHeap by the expiration of
+// the entries found there, leaving the ttlHeap itself untouched.
+type positionHeap struct {
+	h   ttlHeap
+	pos []int
+}
+
+func (p *positionHeap) Len() int { return len(p.pos) }
+
+func (p *positionHeap) Less(i, j int) bool {
+	return expiresBefore(p.h[p.pos[i]], p.h[p.pos[j]])
+}
+
+func (p *positionHeap) Swap(i, j int) { p.pos[i], p.pos[j] = p.pos[j], p.pos[i] }
+
+func (p *positionHeap) Push(x interface{}) { p.pos = append(p.pos, x.(int)) }
+
+func (p *positionHeap) Pop() interface{} {
+	n := len(p.pos)
+	i := p.pos[n-1]
+	p.pos = p.pos[:n-1]
+	return i
+}
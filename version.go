@@ -0,0 +1,36 @@
+package cache2go
+
+// GetVersion returns the version of the entry stored under key. A
+// new entry starts at version 1 and every write of its value, with
+// any of the Set methods, increments it. Versions start over when a
+// key is removed and added again.
+func (c *Cache) GetVersion(key string) (uint64, bool) {
+	c.rlock()
+	defer c.RUnlock()
+	if e, hit := c.cache[key]; hit && !c.expired(e.Value.(*entry), c.now()) {
+		return e.Value.(*entry).version, true
+	}
+	return 0, false
+}
+
+// SetWithVersion stores value only if the entry under key is at
+// expectedVersion, or if key is absent and expectedVersion is zero.
+// It returns the new version and whether the value was stored.
+func (c *Cache) SetWithVersion(key string, value interface{}, expectedVersion uint64) (newVersion uint64, ok bool) {
+	if c.validateValue(key, value) != nil {
+		return 0, false
+	}
+	c.lock()
+	defer c.unlock()
+	var current uint64
+	if e := c.live(key, c.now()); e != nil {
+		current = e.Value.(*entry).version
+	}
+	if current != expectedVersion {
+		return current, false
+	}
+	if _, _, stored := c.set(key, value, 1, 0); !stored {
+		return current, false
+	}
+	return c.cache[key].Value.(*entry).version, true
+}
@@ -0,0 +1,27 @@
+package cache2go
+
+import "testing"
+
+func TestSetWithVersion(t *testing.T) {
+	cache := New(0, 0)
+	if v, ok := cache.SetWithVersion("a", 1, 1); ok || v != 0 {
+		t.Error("missing key should only match version zero: ", v)
+	}
+	v, ok := cache.SetWithVersion("a", 1, 0)
+	if !ok || v != 1 {
+		t.Error("Error adding versioned entry: ", v)
+	}
+	cache.Set("a", 2)
+	if _, ok := cache.SetWithVersion("a", 3, v); ok {
+		t.Error("stale version should be rejected")
+	}
+	if v, _ = cache.GetVersion("a"); v != 2 {
+		t.Error("Set should bump the version: ", v)
+	}
+	if v, ok = cache.SetWithVersion("a", 3, v); !ok || v != 3 {
+		t.Error("Error updating with current version: ", v)
+	}
+	if val, _ := cache.Get("a"); val != 3 {
+		t.Error("Error storing versioned value: ", val)
+	}
+}
@@ -0,0 +1,34 @@
+package cache2go
+
+// OnBeforeEvict sets a callback asked before an entry is evicted for
+// capacity, which keeps the entry when fn returns false. The cache
+// then considers the next candidate, so fn may be called for several
+// entries per eviction, even ones that end up not being evicted.
+// Expired, deleted and flushed entries are removed without asking.
+//
+// When fn vetoes every entry, the new entry that needed room is
+// evicted right away instead, or rejected under OverflowReject, so
+// the cache never grows past maxEntries; a vetoed eviction that makes
+// room for no new entry, as with Reserve, evicts nothing.
+// fn runs while the cache is locked and must not call back into it;
+// if it panics the entry is evicted.
+func (c *Cache) OnBeforeEvict(fn func(key string, value interface{}) (allow bool)) {
+	c.lock()
+	defer c.unlock()
+	c.onBeforeEvict = fn
+}
+
+// spared reports whether en must not be evicted to make room for
+// keep: keep itself, entries in use or pinned, and those vetoed by
+// OnBeforeEvict.
+func (c *Cache) spared(en, keep *entry) bool {
+	if en == keep || en.refs > 0 || en.pinned {
+		return true
+	}
+	if c.onBeforeEvict == nil {
+		return false
+	}
+	allow := true
+	c.safely(func() { allow = c.onBeforeEvict(en.key, en.value) })
+	return !allow
+}
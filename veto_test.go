@@ -0,0 +1,28 @@
+package cache2go
+
+import "testing"
+
+func TestOnBeforeEvict(t *testing.T) {
+	for _, cache := range []*Cache{New(3, 0), New(3, 0, WithCostAwareEviction())} {
+		asked := map[string]int{}
+		cache.OnBeforeEvict(func(key string, value interface{}) bool {
+			asked[key]++
+			return key != "a"
+		})
+		for _, key := range []string{"a", "b", "c", "d"} {
+			cache.Set(key, 1)
+		}
+		if !cache.Contains("a") || cache.Contains("b") || asked["a"] == 0 {
+			t.Error("Error skipping vetoed entry: ", cache.Keys(), asked)
+		}
+		cache.OnBeforeEvict(func(key string, value interface{}) bool { return false })
+		cache.Set("e", 1)
+		if cache.Contains("e") || cache.Len() != 3 {
+			t.Error("Error evicting the new entry when all veto: ", cache.Keys())
+		}
+		cache.Delete("a")
+		if cache.Contains("a") {
+			t.Error("veto applied to Delete")
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package cache2go
+
+// View is a read-only handle on a Cache. It shares the
+// underlying data with the cache it was created from but
+// exposes no methods that add or remove entries.
+type View struct {
+	c *Cache
+}
+
+// ReadOnly returns a read-only view of the cache.
+func (c *Cache) ReadOnly() *View {
+	return &View{c: c}
+}
+
+// Get looks up a key's value from the cache. Like Cache.Get
+// it marks the entry as recently used.
+func (v *View) Get(key string) (value interface{}, ok bool) {
+	return v.c.Get(key)
+}
+
+// Peek looks up a key's value without updating its recency.
+func (v *View) Peek(key string) (value interface{}, ok bool) {
+	return v.c.Peek(key)
+}
+
+// Contains reports whether key is in the cache.
+func (v *View) Contains(key string) bool {
+	return v.c.Contains(key)
+}
+
+// Len returns the number of items in the cache.
+func (v *View) Len() int {
+	return v.c.Len()
+}
+
+// Keys returns the live keys ordered from the most to the
+// least recently used.
+func (v *View) Keys() []string {
+	return v.c.Keys()
+}
@@ -0,0 +1,81 @@
+package cache2go
+
+import "time"
+
+const (
+	// DefaultEntryOverhead estimates the memory the cache uses for
+	// an entry besides its key and value.
+	DefaultEntryOverhead = 128
+	// unknownValueSize is the size DefaultSizer gives to values
+	// other than strings and byte slices.
+	unknownValueSize = 64
+)
+
+// DefaultSizer returns a sizer measuring an entry as the length of
+// its key, plus the length of its value for strings and byte
+// slices or a fixed 64 bytes for other types, plus overhead.
+func DefaultSizer(overhead int64) func(key string, value interface{}) int64 {
+	return func(key string, value interface{}) int64 {
+		size := int64(len(key)) + overhead
+		switch v := value.(type) {
+		case string:
+			size += int64(len(v))
+		case []byte:
+			size += int64(len(v))
+		default:
+			size += unknownValueSize
+		}
+		return size
+	}
+}
+
+// NewBytesLimited creates a cache whose entries may take up to
+// maxBytes, as measured by DefaultSizer(DefaultEntryOverhead) unless
+// overridden WithSizer, evicting the least recently used entries as
+// needed. An entry larger than maxBytes is evicted right away, without
+// evicting the others.
+func NewBytesLimited(maxBytes int64, expire time.Duration, opts ...Option) *Cache {
+	withWeight := func(c *Cache) {
+		c.maxWeight = maxBytes
+		c.sizer = DefaultSizer(DefaultEntryOverhead)
+	}
+	return New(0, expire, append([]Option{withWeight}, opts...)...)
+}
+
+// WithSizer sets how NewBytesLimited measures the entries. fn runs
+// with the cache locked whenever a value is stored and must not call
+// back into it.
+func WithSizer(fn func(key string, value interface{}) int64) Option {
+	return func(c *Cache) {
+		c.sizer = fn
+	}
+}
+
+// Weight returns the total size of the entries of a cache created
+// with NewBytesLimited.
+func (c *Cache) Weight() int64 {
+	c.rlock()
+	defer c.RUnlock()
+	return c.weight
+}
+
+// reweigh measures en after its value changed, then evicts entries
+// until the cache is within its maximum weight, en going last.
+func (c *Cache) reweigh(en *entry) {
+	var w int64
+	c.safely(func() { w = c.sizer(en.key, en.value) })
+	c.weight += w - en.weight
+	en.weight = w
+	if w > c.maxWeight {
+		// evicting the others would not make room
+		c.evict(nil, en)
+		return
+	}
+	for c.weight > c.maxWeight && c.lruIndex.Len() > 0 {
+		n := c.lruIndex.Len()
+		c.removeOldest(en)
+		if c.lruIndex.Len() == n {
+			break
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package cache2go
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBytesLimited(t *testing.T) {
+	cache := NewBytesLimited(3*(DefaultEntryOverhead+11), 0)
+	cache.Set("a", "0123456789")
+	cache.Set("b", []byte("0123456789"))
+	cache.Set("c", "0123456789")
+	if w := cache.Weight(); w != 3*(DefaultEntryOverhead+11) {
+		t.Error("Error weighing entries: ", w)
+	}
+	cache.Set("d", "0123456789")
+	if cache.Contains("a") || cache.Len() != 3 {
+		t.Error("Error evicting by weight: ", cache.Keys())
+	}
+	cache.Set("b", "")
+	cache.Set("e", 42)
+	// "b" shrinks but "e" only fits once "c" and "d" are evicted
+	if fmt.Sprint(cache.Keys()) != "[e b]" || cache.Weight() != 2*DefaultEntryOverhead+66 {
+		t.Error("Error sizing updated and unknown values: ", cache.Keys(), cache.Weight())
+	}
+	cache.Set("huge", string(make([]byte, 1000)))
+	if cache.Contains("huge") || cache.Len() != 2 {
+		t.Error("entry larger than the limit kept: ", cache.Keys())
+	}
+	cache.Delete("b")
+	cache.Flush()
+	if cache.Weight() != 0 {
+		t.Error("Error resetting the weight: ", cache.Weight())
+	}
+
+	cache = NewBytesLimited(10, 0, WithSizer(func(key string, value interface{}) int64 {
+		return int64(value.(int))
+	}))
+	cache.Set("a", 4)
+	cache.Set("b", 4)
+	cache.Set("c", 4)
+	if cache.Contains("a") || cache.Weight() != 8 {
+		t.Error("Error weighing with custom sizer: ", cache.Keys(), cache.Weight())
+	}
+}
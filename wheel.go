@@ -0,0 +1,133 @@
+package cache2go
+
+import (
+	"container/heap"
+	"sort"
+	"time"
+)
+
+const (
+	// wheelTick is the time each slot of the timing wheel covers.
+	wheelTick = 100 * time.Millisecond
+	// wheelSlots is the number of slots of the timing wheel, which
+	// turns around every wheelSlots*wheelTick.
+	wheelSlots = 1024
+)
+
+// timingWheel buckets the expiring entries of a SweepWheel cache by
+// the tick at which they are gone, including the stale grace, modulo
+// wheelSlots. Entries expiring more than a turn ahead share the slot
+// with those of the current turn and are left there when it is
+// swept.
+type timingWheel struct {
+	slots [wheelSlots][]*entry
+	// next is the first tick whose slot the sweep did not finish,
+	// zero until the first entry is added.
+	next int64
+}
+
+func wheelTickOf(t time.Time) int64 { return t.UnixNano() / int64(wheelTick) }
+
+// ttlPush adds en, whose expires is set, to the ttlIndex.
+func (c *Cache) ttlPush(en *entry) {
+	if c.sweep != SweepWheel {
+		heap.Push(&c.ttlIndex, en)
+		return
+	}
+	en.index = len(c.ttlIndex)
+	c.ttlIndex = append(c.ttlIndex, en)
+	c.wheelAdd(en)
+}
+
+// ttlFix updates the ttlIndex after the expires of en changed.
+func (c *Cache) ttlFix(en *entry) {
+	if c.sweep != SweepWheel {
+		heap.Fix(&c.ttlIndex, en.index)
+		return
+	}
+	c.wheelRemove(en)
+	c.wheelAdd(en)
+}
+
+// ttlRemove removes en from the ttlIndex.
+func (c *Cache) ttlRemove(en *entry) {
+	if c.sweep != SweepWheel {
+		heap.Remove(&c.ttlIndex, en.index)
+		return
+	}
+	// with the wheel the ttlIndex is just the set of the expiring
+	// entries, in no particular order
+	last := len(c.ttlIndex) - 1
+	c.ttlIndex.Swap(en.index, last)
+	c.ttlIndex[last] = nil
+	c.ttlIndex = c.ttlIndex[:last]
+	en.index = -1
+	c.wheelRemove(en)
+}
+
+// wheelAdd places en in the slot of the tick it is gone at, or of the
+// next tick to sweep if that one is past.
+func (c *Cache) wheelAdd(en *entry) {
+	w := c.wheel
+	if w.next == 0 {
+		w.next = wheelTickOf(c.now())
+	}
+	tick := wheelTickOf(en.expires.Add(c.staleGrace))
+	if tick < w.next {
+		tick = w.next
+	}
+	en.wheelSlot = int(tick % wheelSlots)
+	en.wheelPos = len(w.slots[en.wheelSlot])
+	w.slots[en.wheelSlot] = append(w.slots[en.wheelSlot], en)
+}
+
+func (c *Cache) wheelRemove(en *entry) {
+	slot := c.wheel.slots[en.wheelSlot]
+	last := len(slot) - 1
+	slot[en.wheelPos] = slot[last]
+	slot[en.wheelPos].wheelPos = en.wheelPos
+	slot[last] = nil
+	c.wheel.slots[en.wheelSlot] = slot[:last]
+}
+
+// turnWheel removes the expired entries of the slots of the ticks
+// from the first one not swept yet to the current one, which holds
+// all the entries gone at now, and returns how many there were. At
+// most one turn of slots is swept.
+func (c *Cache) turnWheel(now time.Time) int {
+	w := c.wheel
+	current := wheelTickOf(now)
+	from := w.next
+	if from == 0 || current-from >= wheelSlots {
+		from = current - wheelSlots + 1
+	}
+	n := 0
+	for tick := from; tick <= current; tick++ {
+		slot := int(tick % wheelSlots)
+		for i := 0; i < len(w.slots[slot]); {
+			en := w.slots[slot][i]
+			if !c.expired(en, now) {
+				i++
+				continue
+			}
+			// removing en moves the last entry of the slot to i
+			c.expire(c.cache[en.key])
+			n++
+		}
+	}
+	// entries may still be added to the current tick
+	w.next = current
+	return n
+}
+
+// wheelExpiringSoon returns up to n keys of a SweepWheel cache
+// ordered by how soon they expire, sorting a copy of the ttlIndex.
+func (c *Cache) wheelExpiringSoon(n int) []string {
+	sorted := append(ttlHeap(nil), c.ttlIndex...)
+	sort.Slice(sorted, func(i, j int) bool { return expiresBefore(sorted[i], sorted[j]) })
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = sorted[i].key
+	}
+	return keys
+}
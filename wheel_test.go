@@ -0,0 +1,105 @@
+package cache2go
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestWheelSweep(t *testing.T) {
+	now := time.Now()
+	cache := New(0, 0, WithSweepStrategy(SweepWheel), func(c *Cache) {
+		c.clock = func() time.Time { return now }
+	})
+	// the sweep is driven by hand below
+	cache.Close()
+	cache.SetDebug(true)
+	cache.SetWithTTL("a", 1, time.Second)
+	cache.SetWithTTL("b", 2, 3*time.Second)
+	cache.SetWithTTL("c", 3, 2*time.Second)
+	// more than a turn of the wheel ahead
+	cache.SetWithTTL("later", 4, 200*time.Second)
+	cache.Set("forever", 5)
+	if keys := cache.ExpiringSoon(3); fmt.Sprint(keys) != "[a c b]" {
+		t.Error("Error ordering by expiration: ", keys)
+	}
+	now = now.Add(1500 * time.Millisecond)
+	if live, expired := cache.LenByState(); live != 4 || expired != 1 {
+		t.Error("Error counting expired entries: ", live, expired)
+	}
+	if n := cache.SweepOnce(); n != 1 || cache.Contains("a") {
+		t.Error("Error sweeping the elapsed slots: ", n)
+	}
+	cache.SetWithTTL("c", 3, time.Hour)
+	now = now.Add(150 * time.Second)
+	if n := cache.SweepOnce(); n != 1 || cache.Contains("b") || !cache.Contains("later") {
+		t.Error("Error sweeping after a turn: ", n, cache.Keys())
+	}
+	now = now.Add(time.Minute)
+	if _, ok := cache.Get("later"); ok || cache.Len() != 2 {
+		t.Error("Error expiring entry on lookup: ", cache.Keys())
+	}
+	cache.Delete("c")
+	if _, expired := cache.LenByState(); expired != 0 || cache.Len() != 1 {
+		t.Error("Error removing entries from the wheel: ", cache.Keys())
+	}
+}
+
+func TestWheelSweepBackground(t *testing.T) {
+	cache := New(0, 0, WithSweepStrategy(SweepWheel))
+	defer cache.Close()
+	for i := 0; i < 100; i++ {
+		cache.SetWithTTL(fmt.Sprint(i), i, time.Millisecond)
+	}
+	cache.SetWithTTL("later", 0, time.Hour)
+	deadline := time.Now().Add(time.Second)
+	for cache.Len() > 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := cache.Len(); n != 1 {
+		t.Error("wheel sweep left expired entries: ", n)
+	}
+}
+
+func benchmarkSetTTL(b *testing.B, s SweepStrategy, entries int) {
+	cache := New(entries, 0, WithSweepStrategy(s))
+	defer cache.Close()
+	keys := make([]string, 2*entries)
+	ttls := make([]time.Duration, len(keys))
+	for i := range keys {
+		keys[i] = fmt.Sprint(i)
+		ttls[i] = time.Minute + time.Duration(rand.Int63n(int64(time.Hour)))
+	}
+	for i := 0; i < entries; i++ {
+		cache.SetWithTTL(keys[i], i, ttls[i])
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j := i % len(keys)
+		cache.SetWithTTL(keys[j], i, ttls[j])
+	}
+}
+
+func BenchmarkSetTTLHeap100k(b *testing.B)  { benchmarkSetTTL(b, SweepFull, 100000) }
+func BenchmarkSetTTLWheel100k(b *testing.B) { benchmarkSetTTL(b, SweepWheel, 100000) }
+func BenchmarkSetTTLHeap1M(b *testing.B)    { benchmarkSetTTL(b, SweepFull, 1000000) }
+func BenchmarkSetTTLWheel1M(b *testing.B)   { benchmarkSetTTL(b, SweepWheel, 1000000) }
+
+// benchmarkSetTTLParallel measures the insert rate of many goroutines
+// setting short lived entries that the sweep keeps removing.
+func benchmarkSetTTLParallel(b *testing.B, s SweepStrategy) {
+	cache := New(0, 0, WithSweepStrategy(s))
+	defer cache.Close()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		r := rand.New(rand.NewSource(rand.Int63()))
+		for pb.Next() {
+			cache.SetWithTTL(fmt.Sprint(r.Intn(1000000)), 0, time.Duration(r.Int63n(int64(time.Second))))
+		}
+	})
+}
+
+func BenchmarkSetTTLParallelHeap(b *testing.B)  { benchmarkSetTTLParallel(b, SweepFull) }
+func BenchmarkSetTTLParallelWheel(b *testing.B) { benchmarkSetTTLParallel(b, SweepWheel) }